@@ -0,0 +1,137 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mhoc/msgoraph/scopes"
+)
+
+// AccessTokenClaims are the claims of a Graph API access token describing who it was issued to and
+// what it grants. See
+// https://docs.microsoft.com/en-us/azure/active-directory/develop/access-tokens.
+type AccessTokenClaims struct {
+	// ApplicationID is the id of the application the token was issued to.
+	ApplicationID string
+	// Audience is the resource the token is meant for, the Graph API.
+	Audience string
+	// ExpiresAt is when the token expires.
+	ExpiresAt time.Time
+	// ObjectID is the id of the user for delegated tokens, or of the service principal for app-only
+	// tokens.
+	ObjectID string
+	// Roles are the application permissions granted to an app-only token.
+	Roles []string
+	// Scopes are the delegated permissions granted to a token issued on behalf of a user.
+	Scopes []string
+	// TenantID is the id of the tenant the token was issued by.
+	TenantID string
+}
+
+// accessTokenPayload is the json form of AccessTokenClaims.
+type accessTokenPayload struct {
+	AppID     string   `json:"appid"`
+	Audience  string   `json:"aud"`
+	AZP       string   `json:"azp"`
+	ExpiresAt int64    `json:"exp"`
+	ObjectID  string   `json:"oid"`
+	Roles     []string `json:"roles"`
+	Scope     string   `json:"scp"`
+	TenantID  string   `json:"tid"`
+}
+
+// ParseAccessTokenClaims decodes the claims of an access token. The token isn't verified; only the
+// Graph API can do that, so the claims are only good for inspecting what a token should grant.
+// Tokens issued for personal Microsoft accounts are opaque and fail to parse.
+func ParseAccessTokenClaims(accessToken string) (*AccessTokenClaims, error) {
+	_, raw, _, _, err := decodeJWT(accessToken)
+	if err != nil {
+		return nil, fmt.Errorf("access token can't be inspected: %v", err)
+	}
+	var payload accessTokenPayload
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return nil, fmt.Errorf("access token can't be inspected: %v", err)
+	}
+	claims := &AccessTokenClaims{
+		ApplicationID: payload.AppID,
+		Audience:      payload.Audience,
+		ExpiresAt:     time.Unix(payload.ExpiresAt, 0),
+		ObjectID:      payload.ObjectID,
+		Roles:         payload.Roles,
+		Scopes:        strings.Fields(payload.Scope),
+		TenantID:      payload.TenantID,
+	}
+	if claims.ApplicationID == "" {
+		claims.ApplicationID = payload.AZP
+	}
+	return claims, nil
+}
+
+// Granted returns the permissions the token grants, its scopes as delegated permissions and its
+// roles as application permissions. Permissions which aren't in the scopes package only have their
+// Permission and Type set.
+func (c *AccessTokenClaims) Granted() scopes.Scopes {
+	var granted scopes.Scopes
+	resolve := func(names []string, typ scopes.PermissionType) {
+		catalog := scopes.All(typ)
+		for _, name := range names {
+			if s := catalog.Find(name); s != nil {
+				granted = append(granted, *s)
+			} else {
+				granted = append(granted, scopes.Scope{Permission: name, Type: typ})
+			}
+		}
+	}
+	resolve(c.Scopes, scopes.PermissionTypeDelegated)
+	resolve(c.Roles, scopes.PermissionTypeApplication)
+	return granted
+}
+
+// Claims decodes the claims of the current access token.
+func (rc *RequestCredentials) Claims() (*AccessTokenClaims, error) {
+	token, _ := rc.Token()
+	if token == "" {
+		return nil, errors.New("no access token, call InitializeCredentials first")
+	}
+	return ParseAccessTokenClaims(token)
+}
+
+// GrantedScopes returns the permissions granted by the current access token.
+func (rc *RequestCredentials) GrantedScopes() (scopes.Scopes, error) {
+	claims, err := rc.Claims()
+	if err != nil {
+		return nil, err
+	}
+	return claims.Granted(), nil
+}
+
+// CheckPermissions is the preflight check service methods run before calling the Graph API. If the
+// client's Config enables PermissionPreflight, it makes sure the client has credentials and that
+// its access token grants at least one of the given permissions, returning a *PermissionError
+// naming the operation otherwise. Permission names are compared case-insensitively, regardless of
+// whether they were granted as delegated or application permissions.
+func CheckPermissions(ctx context.Context, c Client, operation string, anyOf ...string) error {
+	if !configurationOf(c).permissionPreflight() {
+		return nil
+	}
+	if err := refreshCredentials(ctx, c); err != nil {
+		return err
+	}
+	granted, err := c.Credentials().GrantedScopes()
+	if err != nil {
+		// Opaque tokens are left for the Graph API to judge.
+		return nil
+	}
+	for _, s := range granted {
+		for _, permission := range anyOf {
+			if strings.EqualFold(s.Permission, permission) {
+				return nil
+			}
+		}
+	}
+	return &PermissionError{Operation: operation, Required: anyOf, Granted: granted}
+}
@@ -0,0 +1,61 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mhoc/msgoraph/scopes"
+)
+
+// unsignedToken builds a JWT with the given claims and a dummy signature, which is all access
+// token inspection looks at.
+func unsignedToken(t *testing.T, claims map[string]interface{}) string {
+	b, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`)) + "." +
+		base64.RawURLEncoding.EncodeToString(b) + ".c2ln"
+}
+
+func TestCheckPermissions(t *testing.T) {
+	token := unsignedToken(t, map[string]interface{}{"scp": "User.Read offline_access Custom.Permission", "tid": "tid"})
+	c := NewStaticToken(token, time.Now().Add(time.Hour))
+	if err := c.InitializeCredentials(); err != nil {
+		t.Fatal(err)
+	}
+	granted, err := c.Credentials().GrantedScopes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !granted.HasScope(scopes.DelegatedUserRead) || granted.Find("Custom.Permission") == nil {
+		t.Fatalf("unexpected granted scopes %+v", granted)
+	}
+	// Without the preflight enabled, nothing is checked.
+	if err := CheckPermissions(context.Background(), c, "users.ListUsers", "User.Read.All"); err != nil {
+		t.Fatal(err)
+	}
+	c.Config = &Config{PermissionPreflight: true}
+	if err := CheckPermissions(context.Background(), c, "users.GetUser", "user.read"); err != nil {
+		t.Fatal(err)
+	}
+	err = CheckPermissions(context.Background(), c, "users.ListUsers", "User.Read.All", "Directory.Read.All")
+	var permErr *PermissionError
+	if !errors.As(err, &permErr) || !IsForbidden(err) {
+		t.Fatalf("expected a permission error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "users.ListUsers requires one of the permissions User.Read.All, Directory.Read.All") {
+		t.Fatalf("undescriptive error %q", err)
+	}
+	// Opaque tokens can't be inspected, so they're left for the Graph API to judge.
+	opaque := NewStaticToken("opaque", time.Now().Add(time.Hour))
+	opaque.Config = c.Config
+	if err := CheckPermissions(context.Background(), opaque, "users.ListUsers", "User.Read.All"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,123 @@
+package client
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/mhoc/msgoraph/scopes"
+)
+
+// AdminConsent builds the url an administrator visits to grant an application its permissions on
+// behalf of their whole tenant, and handles the redirect Azure AD sends back once they have
+// decided. Admin consent is required before app-only clients such as client.Headless can get a
+// token, and for delegated permissions which have AdminConsentRequired set; see
+// scopes.Scopes.PartitionByConsent. See
+// https://docs.microsoft.com/en-us/azure/active-directory/develop/v2-admin-consent.
+type AdminConsent struct {
+	ApplicationID string
+	Config        *Config
+	// RedirectURI is where Azure AD redirects the administrator to. It has to be registered on the
+	// application.
+	RedirectURI string
+	Scopes      scopes.Scopes
+	// State is echoed back on the redirect, and has to match for the result to be accepted.
+	State string
+	// TenantID is the tenant to request consent in. It defaults to TenantOrganizations, which lets
+	// an administrator of any tenant consent for their own.
+	TenantID string
+}
+
+// AdminConsentResult is the outcome of an admin consent request, as parsed from its redirect.
+type AdminConsentResult struct {
+	// Granted is whether the administrator granted the permissions.
+	Granted bool
+	// Scopes are the permissions which were granted, as returned by Azure AD.
+	Scopes []string
+	// TenantID is the tenant the permissions were granted in.
+	TenantID string
+}
+
+// NewAdminConsent creates an admin consent request for the given application and scopes, with a
+// random state.
+func NewAdminConsent(tenantID string, applicationID string, redirectURI string, scopes scopes.Scopes) (*AdminConsent, error) {
+	state, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+	return &AdminConsent{
+		ApplicationID: applicationID,
+		RedirectURI:   redirectURI,
+		Scopes:        scopes,
+		State:         state,
+		TenantID:      tenantID,
+	}, nil
+}
+
+// URL returns the admin consent url to send the administrator to. Delegated permissions are
+// qualified with the Graph API root url of the Config, as the v2.0 endpoint requires, and the
+// OpenID Connect scopes are left out, as they need no consent. Application permissions can only be
+// consented to statically, so if there are any, the request is for the .default scope of the Graph
+// API instead, which grants every permission configured on the app registration; Azure AD doesn't
+// accept it alongside individual scopes of the same resource.
+func (a *AdminConsent) URL() string {
+	tenant := a.TenantID
+	if tenant == "" {
+		tenant = TenantOrganizations
+	}
+	v := url.Values{}
+	v.Set("client_id", a.ApplicationID)
+	v.Set("redirect_uri", a.RedirectURI)
+	v.Set("scope", adminConsentScope(a.Config, a.Scopes))
+	v.Set("state", a.State)
+	return fmt.Sprintf("%v%v/v2.0/adminconsent?%v", a.Config.Authority(), tenant, v.Encode())
+}
+
+// adminConsentScope builds the scope parameter of an admin consent request for the given scopes.
+func adminConsentScope(config *Config, s scopes.Scopes) string {
+	var perms []string
+	seen := map[string]bool{}
+	for _, scope := range s {
+		if scope.Type == scopes.PermissionTypeApplication {
+			return config.GraphRoot() + ".default"
+		}
+		if isOpenIDScope(scope.Permission) || seen[strings.ToLower(scope.Permission)] {
+			continue
+		}
+		seen[strings.ToLower(scope.Permission)] = true
+		perms = append(perms, config.GraphRoot()+scope.Permission)
+	}
+	return strings.Join(perms, " ")
+}
+
+// isOpenIDScope reports whether the permission is one of the OpenID Connect scopes, which belong to
+// the sign-in rather than to the Graph API.
+func isOpenIDScope(permission string) bool {
+	switch permission {
+	case scopes.DelegatedOpenID.Permission, scopes.DelegatedProfile.Permission, scopes.DelegatedEmail.Permission, scopes.DelegatedOfflineAccess.Permission:
+		return true
+	}
+	return false
+}
+
+// ParseCallback parses the query parameters of the redirect Azure AD sent back. A redirect with
+// the wrong state is rejected, and a declined or failed consent is returned as a *TokenError.
+func (a *AdminConsent) ParseCallback(query url.Values) (*AdminConsentResult, error) {
+	if a.State == "" || subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(a.State)) != 1 {
+		return nil, errors.New("client.AdminConsent: invalid state parameter")
+	}
+	if code := query.Get("error"); code != "" {
+		return nil, &TokenError{Code: code, Description: query.Get("error_description")}
+	}
+	result := &AdminConsentResult{
+		Granted:  strings.EqualFold(query.Get("admin_consent"), "true"),
+		Scopes:   strings.Fields(query.Get("scope")),
+		TenantID: query.Get("tenant"),
+	}
+	if !result.Granted {
+		return nil, errors.New("client.AdminConsent: admin consent was not granted")
+	}
+	return result, nil
+}
@@ -0,0 +1,77 @@
+package client
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/mhoc/msgoraph/scopes"
+)
+
+func TestAdminConsent(t *testing.T) {
+	userConsentable, adminRequired := scopes.Scopes{scopes.DelegatedUserRead, scopes.DelegatedUserReadAll, scopes.ApplicationUserReadAll}.PartitionByConsent()
+	if len(userConsentable) != 1 || len(adminRequired) != 2 {
+		t.Fatalf("unexpected partition %v / %v", userConsentable, adminRequired)
+	}
+	consent, err := NewAdminConsent("", "app", "https://example.com/consent", adminRequired)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(consent.URL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Path != "/organizations/v2.0/adminconsent" {
+		t.Errorf("unexpected path %v", u.Path)
+	}
+	if got := u.Query().Get("scope"); got != "https://graph.microsoft.com/.default" {
+		t.Errorf("unexpected scope %q", got)
+	}
+	if _, err := consent.ParseCallback(url.Values{"state": {"forged"}, "admin_consent": {"True"}}); err == nil {
+		t.Error("expected a forged state to be rejected")
+	}
+	_, err = consent.ParseCallback(url.Values{"state": {consent.State}, "error": {"access_denied"}, "error_description": {"declined"}})
+	var tokenErr *TokenError
+	if !errors.As(err, &tokenErr) || tokenErr.Code != "access_denied" {
+		t.Errorf("expected a declined consent to be a token error, got %v", err)
+	}
+	result, err := consent.ParseCallback(url.Values{"state": {consent.State}, "admin_consent": {"True"}, "tenant": {"tid"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Granted || result.TenantID != "tid" {
+		t.Fatalf("unexpected result %+v", result)
+	}
+}
+
+func TestAdminConsentScope(t *testing.T) {
+	cases := []struct {
+		config   *Config
+		scopes   scopes.Scopes
+		expected string
+	}{
+		{
+			scopes:   scopes.Scopes{scopes.DelegatedOpenID, scopes.DelegatedProfile, scopes.DelegatedEmail, scopes.DelegatedOfflineAccess, scopes.DelegatedUserReadAll, scopes.DelegatedDirectoryReadAll, scopes.DelegatedUserReadAll},
+			expected: "https://graph.microsoft.com/User.Read.All https://graph.microsoft.com/Directory.Read.All",
+		},
+		{
+			scopes:   scopes.Scopes{scopes.DelegatedOpenID, scopes.DelegatedOfflineAccess, scopes.DelegatedUserReadAll, scopes.ApplicationUserReadAll, scopes.ApplicationDirectoryReadAll},
+			expected: "https://graph.microsoft.com/.default",
+		},
+		{
+			config:   &Config{GraphRootURL: USGovernmentGraphRootURL},
+			scopes:   scopes.Scopes{scopes.DelegatedUserReadAll},
+			expected: "https://graph.microsoft.us/User.Read.All",
+		},
+	}
+	for _, c := range cases {
+		consent := &AdminConsent{ApplicationID: "app", Config: c.config, Scopes: c.scopes, State: "state"}
+		u, err := url.Parse(consent.URL())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := u.Query().Get("scope"); got != c.expected {
+			t.Errorf("expected scope %q, got %q", c.expected, got)
+		}
+	}
+}
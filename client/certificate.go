@@ -0,0 +1,174 @@
+package client
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// clientAssertionType is the client_assertion_type sent alongside a signed JWT assertion.
+	clientAssertionType = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+	// assertionLifetime is how long the client assertions signed by a ClientCertificate are valid.
+	assertionLifetime = 10 * time.Minute
+)
+
+// ClientCertificate authenticates an application with a certificate registered on its Azure AD app
+// registration, in place of an application secret. It signs a short-lived JWT client assertion for
+// every token request. See
+// https://docs.microsoft.com/en-us/azure/active-directory/develop/active-directory-certificate-credentials.
+type ClientCertificate struct {
+	// Algorithm is the algorithm assertions are signed with. It defaults to SigningAlgorithmRS256.
+	Algorithm SigningAlgorithm
+
+	// Certificate is the certificate uploaded to the app registration.
+	Certificate *x509.Certificate
+
+	// Key is the RSA private key of the certificate.
+	Key crypto.Signer
+}
+
+// NewClientCertificate creates a certificate credential from a parsed certificate and its RSA
+// private key.
+func NewClientCertificate(cert *x509.Certificate, key crypto.Signer) (*ClientCertificate, error) {
+	if cert == nil {
+		return nil, errors.New("no certificate provided")
+	}
+	// A nil *rsa.PrivateKey is a non-nil crypto.Signer, whose Public method panics.
+	if rsaKey, ok := key.(*rsa.PrivateKey); key == nil || (ok && rsaKey == nil) {
+		return nil, errors.New("no private key provided")
+	}
+	pub, ok := key.Public().(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T, only RSA keys are supported", key)
+	}
+	certPub, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok || certPub.N.Cmp(pub.N) != 0 || certPub.E != pub.E {
+		return nil, errors.New("private key does not match the certificate")
+	}
+	return &ClientCertificate{
+		Algorithm:   SigningAlgorithmRS256,
+		Certificate: cert,
+		Key:         key,
+	}, nil
+}
+
+// ParseClientCertificatePEM creates a certificate credential from PEM data containing both the
+// certificate and its unencrypted private key, in PKCS#1 or PKCS#8 form. If there are several
+// certificates, the one matching the private key is used.
+func ParseClientCertificatePEM(pemData []byte) (*ClientCertificate, error) {
+	var certs []*x509.Certificate
+	var key crypto.Signer
+	for {
+		var block *pem.Block
+		block, pemData = pem.Decode(pemData)
+		if block == nil {
+			break
+		}
+		switch block.Type {
+		case "CERTIFICATE":
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			certs = append(certs, cert)
+		case "RSA PRIVATE KEY":
+			k, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			key = k
+		case "PRIVATE KEY":
+			k, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			signer, ok := k.(crypto.Signer)
+			if !ok {
+				return nil, fmt.Errorf("unsupported private key type %T", k)
+			}
+			key = signer
+		case "ENCRYPTED PRIVATE KEY":
+			return nil, errors.New("encrypted PEM private keys are not supported, decrypt the key or use a PKCS#12 file")
+		}
+	}
+	return matchCertificate(certs, key)
+}
+
+// ParseClientCertificatePKCS12 creates a certificate credential from a password protected PKCS#12
+// (.pfx) file, such as one exported from Azure Key Vault or the Windows certificate store.
+func ParseClientCertificatePKCS12(pfxData []byte, password string) (*ClientCertificate, error) {
+	k, certs, err := decodePKCS12(pfxData, password)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := k.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", k)
+	}
+	return matchCertificate(certs, key)
+}
+
+// matchCertificate returns a certificate credential for whichever certificate belongs to the key.
+func matchCertificate(certs []*x509.Certificate, key crypto.Signer) (*ClientCertificate, error) {
+	if key == nil {
+		return nil, errors.New("no private key found")
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no certificate found")
+	}
+	var err error
+	for _, cert := range certs {
+		var c *ClientCertificate
+		c, err = NewClientCertificate(cert, key)
+		if err == nil {
+			return c, nil
+		}
+	}
+	return nil, err
+}
+
+// Thumbprint returns the base64url encoded SHA-1 thumbprint of the certificate, as sent in the x5t
+// header of assertions.
+func (c *ClientCertificate) Thumbprint() string {
+	sum := sha1.Sum(c.Certificate.Raw)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// Assertion signs a client assertion for the given application, addressed to the given token
+// endpoint.
+func (c *ClientCertificate) Assertion(applicationID string, audience string) (string, error) {
+	alg := c.Algorithm
+	if alg == "" {
+		alg = SigningAlgorithmRS256
+	}
+	header := map[string]interface{}{"x5t": c.Thumbprint()}
+	if alg == SigningAlgorithmPS256 {
+		sum := sha256.Sum256(c.Certificate.Raw)
+		header["x5t#S256"] = base64.RawURLEncoding.EncodeToString(sum[:])
+	}
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", err
+	}
+	now := time.Now()
+	claims := map[string]interface{}{
+		"aud": audience,
+		"exp": now.Add(assertionLifetime).Unix(),
+		"iat": now.Unix(),
+		"iss": applicationID,
+		"jti": base64.RawURLEncoding.EncodeToString(jti),
+		"nbf": now.Unix(),
+		"sub": applicationID,
+	}
+	return signJWT(c.Key, alg, header, claims)
+}
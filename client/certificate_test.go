@@ -0,0 +1,100 @@
+package client
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestParseClientCertificate(t *testing.T) {
+	pemData, err := ioutil.ReadFile("testdata/certificate.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromPEM, err := ParseClientCertificatePEM(pemData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"testdata/certificate_aes.pfx", "testdata/certificate_3des.pfx"} {
+		pfxData, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fromPFX, err := ParseClientCertificatePKCS12(pfxData, "secret")
+		if err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+		if fromPFX.Thumbprint() != fromPEM.Thumbprint() {
+			t.Fatalf("%v: thumbprint mismatch", name)
+		}
+		if _, err := ParseClientCertificatePKCS12(pfxData, "wrong"); err == nil {
+			t.Fatalf("%v: expected an error with the wrong password", name)
+		}
+	}
+}
+
+func TestClientCertificateAssertion(t *testing.T) {
+	pemData, err := ioutil.ReadFile("testdata/certificate.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := ParseClientCertificatePEM(pemData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, alg := range []SigningAlgorithm{SigningAlgorithmRS256, SigningAlgorithmPS256} {
+		cert.Algorithm = alg
+		assertion, err := cert.Assertion("app", "https://login.microsoftonline.com/tenant/oauth2/v2.0/token")
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts := strings.Split(assertion, ".")
+		if len(parts) != 3 {
+			t.Fatalf("malformed assertion %v", assertion)
+		}
+		var header map[string]string
+		hb, _ := base64.RawURLEncoding.DecodeString(parts[0])
+		if err := json.Unmarshal(hb, &header); err != nil {
+			t.Fatal(err)
+		}
+		if header["alg"] != string(alg) || header["x5t"] != cert.Thumbprint() {
+			t.Fatalf("unexpected header %v", header)
+		}
+		sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+		digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		pub := cert.Certificate.PublicKey.(*rsa.PublicKey)
+		if alg == SigningAlgorithmPS256 {
+			err = rsa.VerifyPSS(pub, crypto.SHA256, digest[:], sig, nil)
+		} else {
+			err = rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig)
+		}
+		if err != nil {
+			t.Fatalf("%v signature did not verify: %v", alg, err)
+		}
+	}
+}
+
+func TestNewClientCertificateRequiresKey(t *testing.T) {
+	pemData, err := ioutil.ReadFile("testdata/certificate.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := ParseClientCertificatePEM(pemData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewClientCertificate(cert.Certificate, nil); err == nil {
+		t.Fatal("expected an error without a private key")
+	}
+	if _, err := NewClientCertificate(cert.Certificate, (*rsa.PrivateKey)(nil)); err == nil {
+		t.Fatal("expected an error for a nil RSA private key")
+	}
+	if _, err := NewClientCertificate(nil, cert.Key); err == nil {
+		t.Fatal("expected an error without a certificate")
+	}
+}
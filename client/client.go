@@ -1,6 +1,8 @@
 package client
 
 import (
+	"context"
+	"errors"
 	"sync"
 	"time"
 )
@@ -21,10 +23,149 @@ type Client interface {
 	RefreshCredentials() error
 }
 
+// ContextClient is a Client whose credential requests can be bound to a context. Every client in
+// this package implements it; requests made with a client which doesn't refresh its credentials
+// without a context.
+type ContextClient interface {
+	Client
+
+	// InitializeCredentialsContext is InitializeCredentials, but any requests it makes are bound to
+	// the given context.
+	InitializeCredentialsContext(ctx context.Context) error
+
+	// RefreshCredentialsContext is RefreshCredentials, but any requests it makes are bound to the
+	// given context.
+	RefreshCredentialsContext(ctx context.Context) error
+}
+
+// Configurable is implemented by clients which carry a Config. Every client in this package
+// implements it; requests made with a client which doesn't use the defaults of a nil Config.
+type Configurable interface {
+	// Configuration returns the endpoints and http client that token and Graph API requests made on
+	// behalf of this client should use. It may return nil, in which case the defaults apply.
+	Configuration() *Config
+}
+
+// configurationOf returns the Config of a client, or nil if it isn't Configurable.
+func configurationOf(c Client) *Config {
+	if cc, ok := c.(Configurable); ok {
+		return cc.Configuration()
+	}
+	return nil
+}
+
+// refreshCredentials refreshes the credentials of a client, bound to the given context if the
+// client is a ContextClient.
+func refreshCredentials(ctx context.Context, c Client) error {
+	if cc, ok := c.(ContextClient); ok {
+		return cc.RefreshCredentialsContext(ctx)
+	}
+	return c.RefreshCredentials()
+}
+
 // RequestCredentials stores all the information necessary to authenticate a request with the
-// Microsoft GraphAPI
+// Microsoft GraphAPI. It's safe for concurrent use through its methods; clients refresh it through
+// refresh, which makes sure concurrent requests trigger a single token request.
 type RequestCredentials struct {
+	// AccessToken and AccessTokenExpiresAt hold the current token. Reading or writing them directly
+	// races with refreshes, use Token and SetToken instead.
 	AccessToken          string
 	AccessTokenExpiresAt time.Time
-	AccessTokenUpdating  sync.Mutex
+
+	// AccessTokenUpdating is held while a token is being requested.
+	AccessTokenUpdating sync.Mutex
+
+	// lock guards the token fields and flight.
+	lock sync.Mutex
+	// flight is the token request in progress, if any.
+	flight *tokenFlight
+}
+
+// tokenFlight is a token request shared by everyone who needed the token while it was in progress.
+type tokenFlight struct {
+	done chan struct{}
+	err  error
+}
+
+// Token returns the current access token and when it expires.
+func (rc *RequestCredentials) Token() (string, time.Time) {
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+	return rc.AccessToken, rc.AccessTokenExpiresAt
+}
+
+// SetToken replaces the current access token.
+func (rc *RequestCredentials) SetToken(accessToken string, expiresAt time.Time) {
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+	rc.AccessToken = accessToken
+	rc.AccessTokenExpiresAt = expiresAt
+}
+
+// Valid reports whether there is an access token which is valid for at least skew.
+func (rc *RequestCredentials) Valid(skew time.Duration) bool {
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+	return rc.valid(skew)
+}
+
+func (rc *RequestCredentials) valid(skew time.Duration) bool {
+	return rc.AccessToken != "" && rc.AccessTokenExpiresAt.After(time.Now().Add(skew))
+}
+
+// Invalidate marks the given access token as expired if it's still the current one, so that the
+// next refresh replaces it. Passing the token that was rejected, rather than invalidating
+// unconditionally, keeps concurrent requests rejected with the same token from each forcing a
+// refresh. It reports whether the token was invalidated.
+func (rc *RequestCredentials) Invalidate(accessToken string) bool {
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+	if rc.AccessToken != accessToken {
+		return false
+	}
+	rc.AccessTokenExpiresAt = time.Time{}
+	return true
+}
+
+// refresh makes sure there is an access token valid for at least skew, calling fetch for a new one
+// otherwise. Concurrent callers share a single fetch and its result, though callers whose context
+// is done stop waiting for it. The fetch runs with the context of the caller which started it.
+func (rc *RequestCredentials) refresh(ctx context.Context, skew time.Duration, fetch func(ctx context.Context) (string, time.Time, error)) error {
+	rc.lock.Lock()
+	if rc.valid(skew) {
+		rc.lock.Unlock()
+		return nil
+	}
+	if f := rc.flight; f != nil {
+		rc.lock.Unlock()
+		select {
+		case <-f.done:
+			return f.err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	f := &tokenFlight{done: make(chan struct{})}
+	rc.flight = f
+	rc.lock.Unlock()
+	var accessToken string
+	var expiresAt time.Time
+	defer func() {
+		rc.lock.Lock()
+		if f.err == nil {
+			rc.AccessToken = accessToken
+			rc.AccessTokenExpiresAt = expiresAt
+		}
+		rc.flight = nil
+		rc.lock.Unlock()
+		close(f.done)
+	}()
+	rc.AccessTokenUpdating.Lock()
+	defer rc.AccessTokenUpdating.Unlock()
+	f.err = errors.New("client: token request panicked")
+	accessToken, expiresAt, f.err = fetch(ctx)
+	if f.err == nil && accessToken == "" {
+		f.err = errors.New("client: no access token received")
+	}
+	return f.err
 }
@@ -0,0 +1,79 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRequestCredentialsRefreshOnce(t *testing.T) {
+	var fetches int32
+	release := make(chan struct{})
+	rc := &RequestCredentials{}
+	fetch := func(ctx context.Context) (string, time.Time, error) {
+		atomic.AddInt32(&fetches, 1)
+		<-release
+		return "token", time.Now().Add(time.Hour), nil
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := rc.refresh(context.Background(), time.Minute, fetch); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if fetches != 1 {
+		t.Fatalf("expected concurrent refreshes to share one fetch, got %v", fetches)
+	}
+	if token, _ := rc.Token(); token != "token" {
+		t.Fatalf("unexpected token %q", token)
+	}
+}
+
+func TestRequestCredentialsExpirySkew(t *testing.T) {
+	rc := &RequestCredentials{}
+	rc.SetToken("old", time.Now().Add(time.Minute))
+	if !rc.Valid(0) || rc.Valid(DefaultExpirySkew) {
+		t.Fatal("a token expiring within the skew should no longer be valid")
+	}
+	err := rc.refresh(context.Background(), DefaultExpirySkew, func(ctx context.Context) (string, time.Time, error) {
+		return "new", time.Now().Add(time.Hour), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token, _ := rc.Token(); token != "new" {
+		t.Fatalf("expected the token to be refreshed ahead of its expiry, got %q", token)
+	}
+	if rc.Invalidate("old") || !rc.Invalidate("new") || rc.Valid(0) {
+		t.Fatal("only the current token should be invalidated")
+	}
+}
+
+func TestClientsImplementOptionalInterfaces(t *testing.T) {
+	clients := []Client{
+		&DeviceCode{},
+		&Federated{},
+		&Headless{},
+		&OnBehalfOf{},
+		&Password{},
+		&TokenSource{},
+		&Web{},
+	}
+	for _, c := range clients {
+		if _, ok := c.(ContextClient); !ok {
+			t.Errorf("%T is not a ContextClient", c)
+		}
+		if _, ok := c.(Configurable); !ok {
+			t.Errorf("%T is not Configurable", c)
+		}
+	}
+}
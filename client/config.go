@@ -0,0 +1,142 @@
+package client
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultAuthorityHost is the host of the Azure AD authority in the public cloud.
+	DefaultAuthorityHost = "https://login.microsoftonline.com/"
+	// DefaultGraphRootURL is the root url that the Graph API is hosted on in the public cloud.
+	DefaultGraphRootURL = "https://graph.microsoft.com/"
+	// USGovernmentAuthorityHost is the Azure AD authority host for the US Government cloud.
+	USGovernmentAuthorityHost = "https://login.microsoftonline.us/"
+	// USGovernmentGraphRootURL is the Graph API root url for the US Government L4 cloud.
+	USGovernmentGraphRootURL = "https://graph.microsoft.us/"
+	// USGovernmentDoDGraphRootURL is the Graph API root url for the US Government L5 (DoD) cloud.
+	USGovernmentDoDGraphRootURL = "https://dod-graph.microsoft.us/"
+	// ChinaAuthorityHost is the Azure AD authority host for the cloud operated by 21Vianet.
+	ChinaAuthorityHost = "https://login.chinacloudapi.cn/"
+	// ChinaGraphRootURL is the Graph API root url for the cloud operated by 21Vianet.
+	ChinaGraphRootURL = "https://microsoftgraph.chinacloudapi.cn/"
+
+	// DefaultExpirySkew is how long before their expiry access tokens are refreshed by default.
+	DefaultExpirySkew = 5 * time.Minute
+)
+
+// Config describes where and how a client talks to Microsoft: the authority host token requests
+// are sent to, the root url of the Graph API, and the http client every request goes out over.
+// Any field left at its zero value falls back to the public cloud and http.DefaultClient, so a nil
+// *Config is perfectly valid. A Config must not be modified or copied once it's in use.
+type Config struct {
+	// AuthorityHost is the scheme and host of the Azure AD authority, such as
+	// USGovernmentAuthorityHost.
+	AuthorityHost string
+
+	// GraphRootURL is the scheme and host of the Graph API, such as USGovernmentGraphRootURL. It
+	// should not include a version specifier.
+	GraphRootURL string
+
+	// HTTPClient is used for every token and Graph API request. If it is nil, a client is built
+	// around Transport, or http.DefaultClient is used if that is nil as well.
+	HTTPClient *http.Client
+
+	// Transport is used to build the http client when HTTPClient is nil. This is useful for routing
+	// traffic through a proxy or an instrumented RoundTripper.
+	Transport http.RoundTripper
+
+	// RetryPolicy, if set, wraps the transport of the http client in a RetryTransport so throttled
+	// and transiently failing requests are retried.
+	RetryPolicy *RetryPolicy
+
+	// ExpirySkew is how long before its expiry an access token is refreshed, so that it doesn't
+	// expire while a request is in flight or because of clock drift. Zero uses DefaultExpirySkew,
+	// and a negative value refreshes tokens only once they have expired.
+	ExpirySkew time.Duration
+
+	// JWKSURL is the url of the JSON Web Key Set document ID tokens are verified against. It
+	// defaults to the discovery/v2.0/keys document of the tenant on the authority host.
+	JWKSURL string
+
+	// PermissionPreflight makes service methods check the permissions granted by the access token
+	// before calling the Graph API, failing with a *PermissionError which names the missing
+	// permissions instead of the API's generic 403. Access tokens which can't be inspected, such as
+	// those issued to personal Microsoft accounts, always pass the check.
+	PermissionPreflight bool
+
+	// httpOnce guards building httpClient, the client HTTP returns.
+	httpOnce   sync.Once
+	httpClient *http.Client
+}
+
+// Authority returns the authority host with a trailing slash.
+func (c *Config) Authority() string {
+	if c == nil || c.AuthorityHost == "" {
+		return DefaultAuthorityHost
+	}
+	return withTrailingSlash(c.AuthorityHost)
+}
+
+// GraphRoot returns the Graph API root url with a trailing slash.
+func (c *Config) GraphRoot() string {
+	if c == nil || c.GraphRootURL == "" {
+		return DefaultGraphRootURL
+	}
+	return withTrailingSlash(c.GraphRootURL)
+}
+
+// HTTP returns the http client that requests should be sent with. It's built on first use and
+// shared by every request made with the Config after that.
+func (c *Config) HTTP() *http.Client {
+	if c == nil {
+		return http.DefaultClient
+	}
+	c.httpOnce.Do(func() {
+		c.httpClient = c.buildHTTP()
+	})
+	return c.httpClient
+}
+
+// buildHTTP builds the http client HTTP returns, wrapping its transport in a RetryTransport if
+// there is a RetryPolicy.
+func (c *Config) buildHTTP() *http.Client {
+	httpClient := http.DefaultClient
+	if c.HTTPClient != nil {
+		httpClient = c.HTTPClient
+	} else if c.Transport != nil {
+		httpClient = &http.Client{Transport: c.Transport}
+	}
+	if c.RetryPolicy == nil {
+		return httpClient
+	}
+	withRetries := *httpClient
+	withRetries.Transport = &RetryTransport{Base: httpClient.Transport, Policy: c.RetryPolicy}
+	return &withRetries
+}
+
+// TokenExpirySkew returns how long before their expiry access tokens should be refreshed.
+func (c *Config) TokenExpirySkew() time.Duration {
+	if c == nil || c.ExpirySkew == 0 {
+		return DefaultExpirySkew
+	}
+	if c.ExpirySkew < 0 {
+		return 0
+	}
+	return c.ExpirySkew
+}
+
+// permissionPreflight reports whether service methods should check permissions before calling the
+// Graph API.
+func (c *Config) permissionPreflight() bool {
+	return c != nil && c.PermissionPreflight
+}
+
+func withTrailingSlash(s string) string {
+	if strings.HasSuffix(s, "/") {
+		return s
+	}
+	return s + "/"
+}
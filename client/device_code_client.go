@@ -0,0 +1,185 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/mhoc/msgoraph/scopes"
+)
+
+const (
+	// deviceCodeGrantType is the grant_type used when polling for a device code sign-in.
+	deviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+	// defaultDeviceCodeInterval is the polling interval used if Azure AD doesn't provide one.
+	defaultDeviceCodeInterval = 5 * time.Second
+)
+
+// pollSleep waits out the interval between polls of the token endpoint. Tests replace it to poll
+// without waiting.
+var pollSleep = sleepContext
+
+// DeviceCodePrompt contains what the user needs to complete a device code sign-in from a browser on
+// any device.
+type DeviceCodePrompt struct {
+	// ExpiresAt is when the user code stops being accepted.
+	ExpiresAt time.Time
+	// Message is a human readable instruction containing the user code and verification uri,
+	// localized by Azure AD.
+	Message string
+	// UserCode is the code the user enters at the verification uri.
+	UserCode string
+	// VerificationURI is the page the user signs in at, usually https://microsoft.com/devicelogin.
+	VerificationURI string
+}
+
+// DeviceCode is used to authenticate requests on behalf of a user through the OAuth device
+// authorization grant. Instead of opening a browser and listening for a redirect like client.Web,
+// it hands a short code to the Prompt function, which shows it to the user so they can enter it on
+// another device while the client polls for the result. This makes it suitable for command line
+// tools run over SSH or in containers. Be sure to specify DelegatedOfflineAccess as a scope if you
+// want refreshing to work.
+// See https://docs.microsoft.com/en-us/azure/active-directory/develop/v2-oauth2-device-code.
+type DeviceCode struct {
+	ApplicationID      string
+	Config             *Config
+	Error              error
+	Prompt             func(DeviceCodePrompt)
+	RefreshToken       string
+	RequestCredentials *RequestCredentials
+	Scopes             scopes.Scopes
+	TenantID           string
+}
+
+// NewDeviceCode creates a new client.DeviceCode connection. The prompt function is required; it's
+// called with the user code once sign-in starts, and typically prints the prompt's Message. To
+// initialize the authentication on this, call InitializeCredentials.
+func NewDeviceCode(applicationID string, scopes scopes.Scopes, prompt func(DeviceCodePrompt)) *DeviceCode {
+	return &DeviceCode{
+		ApplicationID:      applicationID,
+		Prompt:             prompt,
+		RequestCredentials: &RequestCredentials{},
+		Scopes:             scopes,
+	}
+}
+
+// Configuration returns the endpoint and transport configuration of this client. Conforms to the
+// client.Configurable interface.
+func (d *DeviceCode) Configuration() *Config {
+	return d.Config
+}
+
+// Credentials returns back the set of request credentials in this client. Conforms to the
+// client.Client interface.
+func (d *DeviceCode) Credentials() *RequestCredentials {
+	return d.RequestCredentials
+}
+
+// InitializeCredentials requests a device code, hands it to the prompt function, and then blocks
+// polling the token endpoint until the user completes or declines sign-in, or the code expires.
+func (d *DeviceCode) InitializeCredentials() error {
+	return d.InitializeCredentialsContext(context.Background())
+}
+
+// InitializeCredentialsContext is InitializeCredentials, but stops polling when the given context
+// is done.
+func (d *DeviceCode) InitializeCredentialsContext(ctx context.Context) error {
+	return d.RequestCredentials.refresh(ctx, d.Config.TokenExpirySkew(), d.signIn)
+}
+
+// signIn requests a device code and polls for the token the user's sign-in with it produces.
+func (d *DeviceCode) signIn(ctx context.Context) (string, time.Time, error) {
+	if d.Prompt == nil {
+		return "", time.Time{}, errors.New("client.DeviceCode: no Prompt function set to show the user code to the user")
+	}
+	tenant := delegatedTenant(d.TenantID)
+	data, err := postForm(ctx, d.Config, fmt.Sprintf("%v%v/oauth2/v2.0/devicecode", d.Config.Authority(), tenant), url.Values{
+		"client_id": {d.ApplicationID},
+		"scope":     {d.Scopes.QueryString()},
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	deviceCode, _ := data["device_code"].(string)
+	userCode, _ := data["user_code"].(string)
+	if deviceCode == "" || userCode == "" {
+		return "", time.Time{}, fmt.Errorf("no device code found in response")
+	}
+	verificationURI, _ := data["verification_uri"].(string)
+	message, _ := data["message"].(string)
+	expiresIn, _ := data["expires_in"].(float64)
+	interval := defaultDeviceCodeInterval
+	if secs, ok := data["interval"].(float64); ok && secs > 0 {
+		interval = time.Duration(secs) * time.Second
+	}
+	prompt := DeviceCodePrompt{
+		ExpiresAt:       time.Now().Add(time.Duration(expiresIn) * time.Second),
+		Message:         message,
+		UserCode:        userCode,
+		VerificationURI: verificationURI,
+	}
+	d.Prompt(prompt)
+	for {
+		if err := pollSleep(ctx, interval); err != nil {
+			return "", time.Time{}, err
+		}
+		token, err := requestToken(ctx, d.Config, tokenURL(d.Config, tenant), url.Values{
+			"client_id":   {d.ApplicationID},
+			"device_code": {deviceCode},
+			"grant_type":  {deviceCodeGrantType},
+		}, d.Scopes.HasScope(scopes.DelegatedOfflineAccess))
+		var tokenErr *TokenError
+		if errors.As(err, &tokenErr) {
+			switch tokenErr.Code {
+			case "authorization_pending":
+				continue
+			case "slow_down":
+				interval += defaultDeviceCodeInterval
+				continue
+			}
+		}
+		if err != nil {
+			return "", time.Time{}, err
+		}
+		if token.RefreshToken != "" {
+			d.RefreshToken = token.RefreshToken
+		}
+		return token.AccessToken, token.ExpiresAt, nil
+	}
+}
+
+// RefreshCredentials will refresh the access token with the refresh token if it is expired. This
+// call will fail if the original sign-in was not made with DelegatedOfflineAccess, in which case
+// InitializeCredentials has to prompt the user again.
+func (d *DeviceCode) RefreshCredentials() error {
+	return d.RefreshCredentialsContext(context.Background())
+}
+
+// RefreshCredentialsContext is RefreshCredentials, with the token request bound to the given
+// context.
+func (d *DeviceCode) RefreshCredentialsContext(ctx context.Context) error {
+	return d.RequestCredentials.refresh(ctx, d.Config.TokenExpirySkew(), d.redeemRefreshToken)
+}
+
+// redeemRefreshToken exchanges the refresh token for a new access token and refresh token.
+func (d *DeviceCode) redeemRefreshToken(ctx context.Context) (string, time.Time, error) {
+	if !d.Scopes.HasScope(scopes.DelegatedOfflineAccess) {
+		return "", time.Time{}, fmt.Errorf("client.DeviceCode: this client was not configured for offline access and token refresh. to configure this, provide an offline scope during the initial sign-in")
+	}
+	if d.RefreshToken == "" {
+		return "", time.Time{}, fmt.Errorf("client.DeviceCode: no refresh token found in device code client. call client.InitializeCredentials to fill this")
+	}
+	token, err := requestToken(ctx, d.Config, tokenURL(d.Config, delegatedTenant(d.TenantID)), url.Values{
+		"client_id":     {d.ApplicationID},
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {d.RefreshToken},
+		"scope":         {d.Scopes.QueryString()},
+	}, true)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	d.RefreshToken = token.RefreshToken
+	return token.AccessToken, token.ExpiresAt, nil
+}
@@ -0,0 +1,156 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mhoc/msgoraph/scopes"
+)
+
+// deviceCodeServer serves the device code endpoint, then answers each poll of the token endpoint
+// with the next of the given token errors, and finally with a token.
+func deviceCodeServer(t *testing.T, pollErrors ...string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Fatal(err)
+		}
+		if strings.HasSuffix(r.URL.Path, "/devicecode") {
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"device_code":      "device",
+				"expires_in":       900,
+				"interval":         2,
+				"message":          "enter ABCD",
+				"user_code":        "ABCD",
+				"verification_uri": "https://microsoft.com/devicelogin",
+			})
+			return
+		}
+		switch r.PostForm.Get("grant_type") {
+		case deviceCodeGrantType:
+			if got := r.PostForm.Get("device_code"); got != "device" {
+				t.Errorf("unexpected device_code %q", got)
+			}
+			if len(pollErrors) > 0 {
+				code := pollErrors[0]
+				pollErrors = pollErrors[1:]
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]interface{}{"error": code})
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"access_token":  "token",
+				"expires_in":    60,
+				"refresh_token": "refresh",
+			})
+		case "refresh_token":
+			if got := r.PostForm.Get("refresh_token"); got != "refresh" {
+				t.Errorf("unexpected refresh_token %q", got)
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"access_token":  "refreshed",
+				"expires_in":    3600,
+				"refresh_token": "refresh2",
+			})
+		default:
+			t.Errorf("unexpected grant_type %q", r.PostForm.Get("grant_type"))
+		}
+	}))
+}
+
+// recordSleeps replaces the poll interval wait for the duration of the test, recording each
+// interval instead of waiting it out.
+func recordSleeps(t *testing.T, sleep func(ctx context.Context, d time.Duration) error) *[]time.Duration {
+	var sleeps []time.Duration
+	pollSleep = func(ctx context.Context, d time.Duration) error {
+		sleeps = append(sleeps, d)
+		if sleep != nil {
+			return sleep(ctx, d)
+		}
+		return nil
+	}
+	t.Cleanup(func() { pollSleep = sleepContext })
+	return &sleeps
+}
+
+func TestDeviceCodeClientPolls(t *testing.T) {
+	sleeps := recordSleeps(t, nil)
+	srv := deviceCodeServer(t, "authorization_pending", "slow_down")
+	defer srv.Close()
+	var prompt DeviceCodePrompt
+	c := NewDeviceCode("app", scopes.Scopes{scopes.DelegatedUserRead, scopes.DelegatedOfflineAccess}, func(p DeviceCodePrompt) {
+		prompt = p
+	})
+	c.Config = &Config{AuthorityHost: srv.URL, HTTPClient: srv.Client()}
+	if err := c.InitializeCredentials(); err != nil {
+		t.Fatal(err)
+	}
+	if prompt.UserCode != "ABCD" || prompt.Message != "enter ABCD" {
+		t.Fatalf("unexpected prompt %+v", prompt)
+	}
+	expected := []time.Duration{2 * time.Second, 2 * time.Second, 7 * time.Second}
+	if len(*sleeps) != len(expected) {
+		t.Fatalf("expected polls after %v, got %v", expected, *sleeps)
+	}
+	for i := range expected {
+		if (*sleeps)[i] != expected[i] {
+			t.Fatalf("expected polls after %v, got %v", expected, *sleeps)
+		}
+	}
+	if c.Credentials().AccessToken != "token" || c.RefreshToken != "refresh" {
+		t.Fatalf("unexpected credentials %q and refresh token %q", c.Credentials().AccessToken, c.RefreshToken)
+	}
+	// The token expires within the expiry skew, so refreshing redeems the refresh token.
+	if err := c.RefreshCredentials(); err != nil {
+		t.Fatal(err)
+	}
+	if c.Credentials().AccessToken != "refreshed" || c.RefreshToken != "refresh2" {
+		t.Fatalf("unexpected credentials %q and refresh token %q after refresh", c.Credentials().AccessToken, c.RefreshToken)
+	}
+}
+
+func TestDeviceCodeClientFails(t *testing.T) {
+	recordSleeps(t, nil)
+	for _, code := range []string{"expired_token", "access_denied"} {
+		srv := deviceCodeServer(t, "authorization_pending", code)
+		c := NewDeviceCode("app", scopes.Scopes{scopes.DelegatedUserRead}, func(DeviceCodePrompt) {})
+		c.Config = &Config{AuthorityHost: srv.URL, HTTPClient: srv.Client()}
+		err := c.InitializeCredentials()
+		srv.Close()
+		var tokenErr *TokenError
+		if !errors.As(err, &tokenErr) || tokenErr.Code != code {
+			t.Fatalf("expected a %v token error, got %v", code, err)
+		}
+	}
+}
+
+func TestDeviceCodeClientCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sleeps := recordSleeps(t, func(ctx context.Context, d time.Duration) error {
+		cancel()
+		return sleepContext(ctx, d)
+	})
+	srv := deviceCodeServer(t)
+	defer srv.Close()
+	c := NewDeviceCode("app", scopes.Scopes{scopes.DelegatedUserRead}, func(DeviceCodePrompt) {})
+	c.Config = &Config{AuthorityHost: srv.URL, HTTPClient: srv.Client()}
+	if err := c.InitializeCredentialsContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the sign-in to be cancelled, got %v", err)
+	}
+	if len(*sleeps) != 1 {
+		t.Fatalf("expected polling to stop at the first wait, got %v waits", len(*sleeps))
+	}
+}
+
+func TestDeviceCodeClientRequiresPrompt(t *testing.T) {
+	c := NewDeviceCode("app", scopes.Scopes{scopes.DelegatedUserRead}, nil)
+	if err := c.InitializeCredentials(); err == nil {
+		t.Fatal("expected an error without a prompt function")
+	}
+}
@@ -0,0 +1,144 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mhoc/msgoraph/scopes"
+)
+
+var (
+	// ErrNotFound is matched by errors.Is for any GraphError with a 404 status code.
+	ErrNotFound = errors.New("resource not found")
+	// ErrThrottled is matched by errors.Is for any GraphError which indicates the request was
+	// throttled; a 429, or a 503 carrying a Retry-After header.
+	ErrThrottled = errors.New("request throttled")
+	// ErrUnauthorized is matched by errors.Is for any GraphError with a 401 status code.
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrForbidden is matched by errors.Is for any GraphError with a 403 status code.
+	ErrForbidden = errors.New("forbidden")
+)
+
+// GraphError is returned for every response from the Graph API with a non-2xx status code. It
+// carries the error object from the response body, documented at
+// https://docs.microsoft.com/en-us/graph/errors, along with the response status and headers.
+type GraphError struct {
+	StatusCode int
+	Code       string
+	Message    string
+	RequestID  string
+	Date       string
+	Header     http.Header
+}
+
+// Error implements the error interface.
+func (e *GraphError) Error() string {
+	msg := fmt.Sprintf("graph api: %v", e.StatusCode)
+	if e.Code != "" {
+		msg += " " + e.Code
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	if e.RequestID != "" {
+		msg += fmt.Sprintf(" (request-id %v)", e.RequestID)
+	}
+	return msg
+}
+
+// RetryAfter returns the delay the server asked for before the request is retried, and whether the
+// response carried a Retry-After header at all.
+func (e *GraphError) RetryAfter() (time.Duration, bool) {
+	return retryAfter(e.Header.Get("Retry-After"))
+}
+
+// Is lets errors.Is match a GraphError against the sentinel errors in this package, such as
+// ErrNotFound.
+func (e *GraphError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrThrottled:
+		return e.StatusCode == http.StatusTooManyRequests ||
+			(e.StatusCode == http.StatusServiceUnavailable && e.Header.Get("Retry-After") != "")
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	}
+	return false
+}
+
+// TokenError is returned when Azure AD rejects a request made while acquiring credentials, such as
+// an invalid_client or invalid_grant from the token endpoint. See
+// https://docs.microsoft.com/en-us/azure/active-directory/develop/reference-aadsts-error-codes.
+type TokenError struct {
+	StatusCode  int
+	Code        string
+	Description string
+}
+
+// Error implements the error interface.
+func (e *TokenError) Error() string {
+	if e.Description == "" {
+		return e.Code
+	}
+	return fmt.Sprintf("%v: %v", e.Code, e.Description)
+}
+
+// PermissionError is returned by the permission preflight check, enabled with
+// Config.PermissionPreflight, when the access token doesn't grant any of the permissions an
+// operation accepts. It's matched by errors.Is against ErrForbidden, as the Graph API would have
+// refused the request with a 403.
+type PermissionError struct {
+	// Operation is the service method which was refused, such as "users.ListUsers".
+	Operation string
+	// Required lists the permissions the operation accepts, least privileged first.
+	Required []string
+	// Granted is every permission the access token grants.
+	Granted scopes.Scopes
+}
+
+// Error implements the error interface.
+func (e *PermissionError) Error() string {
+	granted := "no permissions"
+	if len(e.Granted) > 0 {
+		names := make([]string, len(e.Granted))
+		for i, s := range e.Granted {
+			names[i] = s.Permission
+		}
+		granted = strings.Join(names, ", ")
+	}
+	return fmt.Sprintf("%v requires one of the permissions %v, but the access token only grants %v", e.Operation, strings.Join(e.Required, ", "), granted)
+}
+
+// Is lets errors.Is match a PermissionError against ErrForbidden.
+func (e *PermissionError) Is(target error) bool {
+	return target == ErrForbidden
+}
+
+// IsNotFound returns true if the error, or any error it wraps, is a GraphError with a 404 status.
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound)
+}
+
+// IsThrottled returns true if the error, or any error it wraps, is a GraphError indicating the
+// request was throttled.
+func IsThrottled(err error) bool {
+	return errors.Is(err, ErrThrottled)
+}
+
+// IsUnauthorized returns true if the error, or any error it wraps, is a GraphError with a 401
+// status.
+func IsUnauthorized(err error) bool {
+	return errors.Is(err, ErrUnauthorized)
+}
+
+// IsForbidden returns true if the error, or any error it wraps, is a GraphError with a 403 status
+// or a PermissionError.
+func IsForbidden(err error) bool {
+	return errors.Is(err, ErrForbidden)
+}
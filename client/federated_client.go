@@ -0,0 +1,123 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Environment variables set by Azure AD workload identity, as read by NewFederatedFromEnvironment.
+const (
+	EnvironmentClientID           = "AZURE_CLIENT_ID"
+	EnvironmentTenantID           = "AZURE_TENANT_ID"
+	EnvironmentFederatedTokenFile = "AZURE_FEDERATED_TOKEN_FILE"
+)
+
+// Federated is used to authenticate requests in the context of a backend app with a token issued by
+// another identity provider, such as a Kubernetes service account token, which the app
+// registration trusts through a federated identity credential. The token is read from
+// AssertionFile for every token request, since the platform rotates it, and exchanged for a Graph
+// API token with the client_credentials grant. Like client.Headless, TenantID must identify a
+// single tenant. See
+// https://docs.microsoft.com/en-us/azure/active-directory/develop/workload-identity-federation.
+type Federated struct {
+	ApplicationID      string
+	AssertionFile      string
+	Config             *Config
+	Error              error
+	RequestCredentials *RequestCredentials
+	TenantID           string
+}
+
+// NewFederated creates a new client.Federated connection for the given tenant, which reads its
+// assertion from the given file.
+func NewFederated(tenantID string, applicationID string, assertionFile string) *Federated {
+	return &Federated{
+		ApplicationID:      applicationID,
+		AssertionFile:      assertionFile,
+		RequestCredentials: &RequestCredentials{},
+		TenantID:           tenantID,
+	}
+}
+
+// NewFederatedFromEnvironment creates a new client.Federated connection configured by the
+// environment variables Azure AD workload identity injects into pods.
+func NewFederatedFromEnvironment() (*Federated, error) {
+	var missing []string
+	for _, name := range []string{EnvironmentClientID, EnvironmentTenantID, EnvironmentFederatedTokenFile} {
+		if os.Getenv(name) == "" {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("client.Federated: missing environment variables %v", strings.Join(missing, ", "))
+	}
+	return NewFederated(os.Getenv(EnvironmentTenantID), os.Getenv(EnvironmentClientID), os.Getenv(EnvironmentFederatedTokenFile)), nil
+}
+
+// Configuration returns the endpoint and transport configuration of this client. Conforms to the
+// client.Configurable interface.
+func (f *Federated) Configuration() *Config {
+	return f.Config
+}
+
+// Credentials returns back the set of request credentials in this client. Conforms to the
+// client.Client interface.
+func (f *Federated) Credentials() *RequestCredentials {
+	return f.RequestCredentials
+}
+
+// InitializeCredentials exchanges the current contents of the assertion file for a token.
+func (f *Federated) InitializeCredentials() error {
+	return f.InitializeCredentialsContext(context.Background())
+}
+
+// InitializeCredentialsContext is InitializeCredentials, with the token request bound to the given
+// context.
+func (f *Federated) InitializeCredentialsContext(ctx context.Context) error {
+	return f.RequestCredentials.refresh(ctx, f.Config.TokenExpirySkew(), f.fetchToken)
+}
+
+// fetchToken exchanges the current contents of the assertion file for a token.
+func (f *Federated) fetchToken(ctx context.Context) (string, time.Time, error) {
+	err := validateAppOnlyTenant(f.TenantID)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	data, err := ioutil.ReadFile(f.AssertionFile)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("client.Federated: reading assertion file: %v", err)
+	}
+	assertion := strings.TrimSpace(string(data))
+	if assertion == "" {
+		return "", time.Time{}, errors.New("client.Federated: the assertion file is empty")
+	}
+	token, err := requestToken(ctx, f.Config, tokenURL(f.Config, f.TenantID), url.Values{
+		"client_assertion":      {assertion},
+		"client_assertion_type": {clientAssertionType},
+		"client_id":             {f.ApplicationID},
+		"grant_type":            {"client_credentials"},
+		"scope":                 {f.Config.GraphRoot() + ".default"},
+	}, false)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token.AccessToken, token.ExpiresAt, nil
+}
+
+// RefreshCredentials exchanges the assertion file for a new token once the current one has
+// expired. This just proxies through to InitializeCredentials.
+func (f *Federated) RefreshCredentials() error {
+	return f.RefreshCredentialsContext(context.Background())
+}
+
+// RefreshCredentialsContext is RefreshCredentials, with the token request bound to the given
+// context.
+func (f *Federated) RefreshCredentialsContext(ctx context.Context) error {
+	return f.InitializeCredentialsContext(ctx)
+}
@@ -0,0 +1,44 @@
+package client
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestFederatedClientReadsAssertionFile(t *testing.T) {
+	f, err := ioutil.TempFile("", "assertion")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("federated-token\n")
+	f.Close()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Fatal(err)
+		}
+		if got := r.PostForm.Get("client_assertion"); got != "federated-token" {
+			t.Errorf("unexpected client_assertion %q", got)
+		}
+		if got := r.PostForm.Get("client_assertion_type"); got != clientAssertionType {
+			t.Errorf("unexpected client_assertion_type %v", got)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "token",
+			"expires_in":   3600,
+		})
+	}))
+	defer srv.Close()
+	c := NewFederated("tenant", "app", f.Name())
+	c.Config = &Config{AuthorityHost: srv.URL, HTTPClient: srv.Client()}
+	if err := c.InitializeCredentials(); err != nil {
+		t.Fatal(err)
+	}
+	if c.Credentials().AccessToken != "token" {
+		t.Fatalf("expected access token to be set, got %q", c.Credentials().AccessToken)
+	}
+}
@@ -1,10 +1,8 @@
 package client
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"io/ioutil"
-	"net/http"
 	"net/url"
 	"time"
 
@@ -12,92 +10,130 @@ import (
 )
 
 // Headless is used to authenticate requests in the context of a backend app. This is the most
-// common way for applications to authenticate with the api.
+// common way for applications to authenticate with the api. The application authenticates with
+// either its ApplicationSecret or, if set, a Certificate. App-only tokens have to be requested from
+// a specific tenant, so TenantID must be set to the directory (tenant) id or a verified domain of
+// the tenant the application acts in. If TokenCache is set, tokens are shared through it and only
+// requested once the cached one expires.
 type Headless struct {
 	ApplicationID      string
 	ApplicationSecret  string
+	Certificate        *ClientCertificate
+	Config             *Config
 	Error              error
 	RefreshToken       string
 	RequestCredentials *RequestCredentials
 	Scopes             scopes.Scopes
+	TenantID           string
+	TokenCache         TokenCache
 }
 
-// NewHeadless creates a new headless connection.
-func NewHeadless(applicationID string, applicationSecret string, scopes scopes.Scopes) *Headless {
+// NewHeadless creates a new headless connection for the given tenant.
+func NewHeadless(tenantID string, applicationID string, applicationSecret string, scopes scopes.Scopes) *Headless {
 	return &Headless{
 		ApplicationID:      applicationID,
 		ApplicationSecret:  applicationSecret,
 		RequestCredentials: &RequestCredentials{},
 		Scopes:             scopes,
+		TenantID:           tenantID,
 	}
 }
 
+// NewHeadlessWithCertificate creates a new headless connection for the given tenant which
+// authenticates with a certificate instead of an application secret.
+func NewHeadlessWithCertificate(tenantID string, applicationID string, certificate *ClientCertificate, scopes scopes.Scopes) *Headless {
+	return &Headless{
+		ApplicationID:      applicationID,
+		Certificate:        certificate,
+		RequestCredentials: &RequestCredentials{},
+		Scopes:             scopes,
+		TenantID:           tenantID,
+	}
+}
+
+// Configuration returns the endpoint and transport configuration of this client.
+func (h *Headless) Configuration() *Config {
+	return h.Config
+}
+
 // Credentials returns back the set of credentials used for every request.
-func (h Headless) Credentials() *RequestCredentials {
+func (h *Headless) Credentials() *RequestCredentials {
 	return h.RequestCredentials
 }
 
 // InitializeCredentials will make an initial oauth2 token request for a new token.
-func (h Headless) InitializeCredentials() error {
-	h.RequestCredentials.AccessTokenUpdating.Lock()
-	defer h.RequestCredentials.AccessTokenUpdating.Unlock()
-	if h.RequestCredentials.AccessToken != "" && h.RequestCredentials.AccessTokenExpiresAt.After(time.Now()) {
-		return nil
-	}
-	tokenURI, err := url.Parse("https://login.microsoftonline.com/common/oauth2/v2.0/token")
+func (h *Headless) InitializeCredentials() error {
+	return h.InitializeCredentialsContext(context.Background())
+}
+
+// InitializeCredentialsContext is InitializeCredentials, with the token request bound to the given
+// context.
+func (h *Headless) InitializeCredentialsContext(ctx context.Context) error {
+	return h.RequestCredentials.refresh(ctx, h.Config.TokenExpirySkew(), h.fetchToken)
+}
+
+// fetchToken gets a new app-only token, from the token cache if it has one which is still valid.
+func (h *Headless) fetchToken(ctx context.Context) (string, time.Time, error) {
+	err := validateAppOnlyTenant(h.TenantID)
 	if err != nil {
-		return err
+		return "", time.Time{}, err
 	}
-	resp, err := http.PostForm(tokenURI.String(), url.Values{
-		"client_id":     {h.ApplicationID},
-		"client_secret": {h.ApplicationSecret},
-		"grant_type":    {"client_credentials"},
-		"scope":         {"https://graph.microsoft.com/.default"},
-	})
-	if err != nil {
-		return err
+	if h.TokenCache != nil {
+		cached, err := h.TokenCache.Load(h.tokenCacheKey())
+		if err != nil {
+			return "", time.Time{}, fmt.Errorf("client.Headless: loading token cache: %v", err)
+		}
+		if cached != nil && cached.AccessToken != "" && cached.AccessTokenExpiresAt.After(time.Now().Add(h.Config.TokenExpirySkew())) {
+			return cached.AccessToken, cached.AccessTokenExpiresAt, nil
+		}
 	}
-	b, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return err
+	tokenURI := tokenURL(h.Config, h.TenantID)
+	form := url.Values{
+		"client_id":  {h.ApplicationID},
+		"grant_type": {"client_credentials"},
+		"scope":      {h.Config.GraphRoot() + ".default"},
 	}
-	var data map[string]interface{}
-	err = json.Unmarshal(b, &data)
+	err = setClientAuthentication(form, tokenURI, h.ApplicationID, h.ApplicationSecret, h.Certificate)
 	if err != nil {
-		return err
-	}
-	serverErrCode, ok := data["error"].(string)
-	if ok {
-		serverErr, ok := data["error_description"].(string)
-		if ok {
-			return fmt.Errorf("%v: %v", serverErrCode, serverErr)
-		}
-		return fmt.Errorf(serverErrCode)
+		return "", time.Time{}, err
 	}
-	accessToken, ok := data["access_token"].(string)
-	if !ok || accessToken == "" {
-		return fmt.Errorf("no access token found in response")
+	token, err := requestToken(ctx, h.Config, tokenURI, form, h.Scopes.HasScope(scopes.DelegatedOfflineAccess))
+	if err != nil {
+		return "", time.Time{}, err
 	}
-	durationSecs, ok := data["expires_in"].(float64)
-	if !ok || durationSecs == 0 {
-		return fmt.Errorf("no token duration found in response")
+	if token.RefreshToken != "" {
+		h.RefreshToken = token.RefreshToken
 	}
-	if h.Scopes.HasScope(scopes.DelegatedOfflineAccess) {
-		refreshToken, ok := data["refresh_token"].(string)
-		if !ok || refreshToken == "" {
-			return fmt.Errorf("no refresh token found in response")
+	if h.TokenCache != nil {
+		err = h.TokenCache.Save(h.tokenCacheKey(), &CachedToken{
+			AccessToken:          token.AccessToken,
+			AccessTokenExpiresAt: token.ExpiresAt,
+		})
+		if err != nil {
+			return "", time.Time{}, fmt.Errorf("client.Headless: saving token cache: %v", err)
 		}
-		h.RefreshToken = refreshToken
 	}
-	expiresAt := time.Now().Add(time.Duration(durationSecs) * time.Second)
-	h.RequestCredentials.AccessToken = accessToken
-	h.RequestCredentials.AccessTokenExpiresAt = expiresAt
-	return nil
+	return token.AccessToken, token.ExpiresAt, nil
 }
 
 // RefreshCredentials will refresh the connection credentials. This just proxies through to
 // InitializeCredentials, because in the context of a headless appliction we should probably already
 // have the application secret key.
-func (h Headless) RefreshCredentials() error {
-	return h.InitializeCredentials()
+func (h *Headless) RefreshCredentials() error {
+	return h.RefreshCredentialsContext(context.Background())
+}
+
+// RefreshCredentialsContext is RefreshCredentials, with the token request bound to the given
+// context.
+func (h *Headless) RefreshCredentialsContext(ctx context.Context) error {
+	return h.InitializeCredentialsContext(ctx)
+}
+
+// tokenCacheKey returns the key app-only tokens of this client are cached under. App-only tokens
+// always carry every permission granted to the application, so they're keyed by the .default scope
+// rather than the configured scopes.
+func (h *Headless) tokenCacheKey() TokenCacheKey {
+	key := newTokenCacheKey(h.ApplicationID, h.TenantID, "", nil)
+	key.Scopes = h.Config.GraphRoot() + ".default"
+	return key
 }
@@ -1,17 +1,51 @@
 package client
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/mhoc/msgoraph/scopes"
 )
 
 func TestHeadlessClientInitialization(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/tenant/oauth2/v2.0/token" {
+			t.Errorf("unexpected token path %v", r.URL.Path)
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Fatal(err)
+		}
+		if got := r.PostForm.Get("grant_type"); got != "client_credentials" {
+			t.Errorf("unexpected grant_type %v", got)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "token",
+			"expires_in":   3600,
+		})
+	}))
+	defer srv.Close()
+	tenantID := "tenant"
 	applicationID := ""
 	applicationSecret := ""
-	c := NewHeadless(applicationID, applicationSecret, scopes.All(scopes.PermissionTypeApplication))
+	c := NewHeadless(tenantID, applicationID, applicationSecret, scopes.All(scopes.PermissionTypeApplication))
+	c.Config = &Config{AuthorityHost: srv.URL, HTTPClient: srv.Client()}
 	err := c.InitializeCredentials()
 	if err != nil {
-		t.Fatalf(err.Error())
+		t.Fatal(err)
+	}
+	if c.Credentials().AccessToken != "token" {
+		t.Fatalf("expected access token to be set, got %q", c.Credentials().AccessToken)
+	}
+}
+
+func TestHeadlessClientRequiresTenant(t *testing.T) {
+	for _, tenantID := range []string{"", TenantCommon, "Organizations"} {
+		c := NewHeadless(tenantID, "", "", scopes.All(scopes.PermissionTypeApplication))
+		c.Config = &Config{AuthorityHost: "http://127.0.0.1:0"}
+		if err := c.InitializeCredentials(); err == nil {
+			t.Fatalf("expected client_credentials against tenant %q to be rejected", tenantID)
+		}
 	}
 }
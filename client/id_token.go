@@ -0,0 +1,217 @@
+package client
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// idTokenLeeway is the clock skew tolerated when checking the expiry of ID tokens.
+	idTokenLeeway = 5 * time.Minute
+
+	// keySetRefetchInterval is how long a key set is trusted to be complete before a token signed
+	// with an unknown key makes it fetched again, which is how signing key rollover is picked up.
+	keySetRefetchInterval = time.Minute
+)
+
+// IDTokenClaims are the claims of a validated ID token, identifying the user who signed in. Which
+// of them are filled in depends on the scopes of the sign-in: scopes.DelegatedOpenID is required
+// for an ID token at all, scopes.DelegatedProfile adds Name and PreferredUsername, and
+// scopes.DelegatedEmail adds Email. See
+// https://docs.microsoft.com/en-us/azure/active-directory/develop/id-tokens.
+type IDTokenClaims struct {
+	// Audience is the application the token was issued to.
+	Audience string
+	// Email is the email address of the user, if they have one.
+	Email string
+	// ExpiresAt is when the token expires.
+	ExpiresAt time.Time
+	// IssuedAt is when the token was issued.
+	IssuedAt time.Time
+	// Issuer is the tenant specific authority which issued the token.
+	Issuer string
+	// Name is the display name of the user.
+	Name string
+	// Nonce is the nonce sent with the sign-in request.
+	Nonce string
+	// ObjectID is the id of the user in the directory, as used by the users package.
+	ObjectID string
+	// PreferredUsername is the user name the user signs in with, usually their user principal name.
+	PreferredUsername string
+	// Subject is an identifier of the user which is unique to the application.
+	Subject string
+	// TenantID is the id of the tenant the user signed in to.
+	TenantID string
+}
+
+// idTokenPayload is the json form of IDTokenClaims.
+type idTokenPayload struct {
+	Audience          string `json:"aud"`
+	Email             string `json:"email"`
+	ExpiresAt         int64  `json:"exp"`
+	IssuedAt          int64  `json:"iat"`
+	Issuer            string `json:"iss"`
+	Name              string `json:"name"`
+	Nonce             string `json:"nonce"`
+	ObjectID          string `json:"oid"`
+	PreferredUsername string `json:"preferred_username"`
+	Subject           string `json:"sub"`
+	TenantID          string `json:"tid"`
+}
+
+// validateIDToken verifies the signature of an ID token against the keys of the tenant, then checks
+// that it was issued by the authority, to the application, for the sign-in with the given nonce,
+// and hasn't expired. An empty nonce skips that check, as tokens from a refresh carry none.
+func validateIDToken(ctx context.Context, config *Config, keys *keySet, tenant string, applicationID string, nonce string, idToken string) (*IDTokenClaims, error) {
+	header, raw, signingInput, signature, err := decodeJWT(idToken)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id token: %v", err)
+	}
+	alg, _ := header["alg"].(string)
+	kid, _ := header["kid"].(string)
+	key, err := keys.key(ctx, config, keysURL(config, tenant), kid)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id token: %v", err)
+	}
+	if err := verifyJWT(key, SigningAlgorithm(alg), signingInput, signature); err != nil {
+		return nil, fmt.Errorf("invalid id token: bad signature: %v", err)
+	}
+	var payload idTokenPayload
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return nil, fmt.Errorf("invalid id token: %v", err)
+	}
+	claims := &IDTokenClaims{
+		Audience:          payload.Audience,
+		Email:             payload.Email,
+		ExpiresAt:         time.Unix(payload.ExpiresAt, 0),
+		IssuedAt:          time.Unix(payload.IssuedAt, 0),
+		Issuer:            payload.Issuer,
+		Name:              payload.Name,
+		Nonce:             payload.Nonce,
+		ObjectID:          payload.ObjectID,
+		PreferredUsername: payload.PreferredUsername,
+		Subject:           payload.Subject,
+		TenantID:          payload.TenantID,
+	}
+	// Tokens from the multi-tenant authorities are issued by the tenant the user belongs to, so the
+	// expected issuer is built from the token's own tenant id, which has to match the configured
+	// tenant whenever that is a tenant id itself. This assumes the issuer is on the configured
+	// authority host, as it is in the public and national clouds. Authorities whose issuer differs,
+	// such as Azure AD B2C or a custom domain, aren't supported; that would need the issuer from the
+	// tenant's OpenID discovery document.
+	if claims.TenantID == "" || claims.Issuer != fmt.Sprintf("%v%v/v2.0", config.Authority(), claims.TenantID) {
+		return nil, fmt.Errorf("invalid id token: unexpected issuer %q", claims.Issuer)
+	}
+	if isTenantID(tenant) && !strings.EqualFold(tenant, claims.TenantID) {
+		return nil, fmt.Errorf("invalid id token: issued by tenant %v rather than %v", claims.TenantID, tenant)
+	}
+	if claims.Audience != applicationID {
+		return nil, fmt.Errorf("invalid id token: issued to %q rather than this application", claims.Audience)
+	}
+	if nonce != "" && claims.Nonce != nonce {
+		return nil, errors.New("invalid id token: nonce does not match the sign-in request")
+	}
+	if !claims.ExpiresAt.Add(idTokenLeeway).After(time.Now()) {
+		return nil, fmt.Errorf("invalid id token: expired at %v", claims.ExpiresAt)
+	}
+	return claims, nil
+}
+
+// isTenantID reports whether the tenant is a directory id, rather than a multi-tenant authority or
+// a domain name, which can't be compared against the tid claim.
+func isTenantID(tenant string) bool {
+	switch strings.ToLower(tenant) {
+	case "", TenantCommon, TenantOrganizations, TenantConsumers:
+		return false
+	}
+	return !strings.Contains(tenant, ".")
+}
+
+// keySet caches the RSA signing keys of a JWKS document by key id.
+type keySet struct {
+	lock    sync.Mutex
+	keys    map[string]*rsa.PublicKey
+	fetched time.Time
+}
+
+// key returns the key with the given id, fetching the JWKS document at uri if it isn't known yet.
+func (ks *keySet) key(ctx context.Context, config *Config, uri string, kid string) (*rsa.PublicKey, error) {
+	ks.lock.Lock()
+	defer ks.lock.Unlock()
+	if key, ok := ks.keys[kid]; ok {
+		return key, nil
+	}
+	if ks.keys != nil && time.Since(ks.fetched) < keySetRefetchInterval {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	keys, err := fetchKeySet(ctx, config, uri)
+	if err != nil {
+		return nil, err
+	}
+	ks.keys = keys
+	ks.fetched = time.Now()
+	if key, ok := ks.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// fetchKeySet downloads and decodes the RSA keys of a JWKS document.
+func fetchKeySet(ctx context.Context, config *Config, uri string) (map[string]*rsa.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := config.HTTP().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching signing keys: unexpected status %v", resp.StatusCode)
+	}
+	var doc struct {
+		Keys []struct {
+			E   string `json:"e"`
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("fetching signing keys: %v", err)
+	}
+	keys := map[string]*rsa.PublicKey{}
+	for _, k := range doc.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("fetching signing keys: bad modulus for key %q: %v", k.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("fetching signing keys: bad exponent for key %q: %v", k.Kid, err)
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,96 @@
+package client
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestValidateIDToken(t *testing.T) {
+	pemData, err := ioutil.ReadFile("testdata/certificate.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := ParseClientCertificatePEM(pemData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := cert.Key.Public().(*rsa.PublicKey)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/common/discovery/v2.0/keys" {
+			t.Errorf("unexpected keys path %v", r.URL.Path)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "key",
+				"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			}},
+		})
+	}))
+	defer srv.Close()
+	config := &Config{AuthorityHost: srv.URL, HTTPClient: srv.Client()}
+	sign := func(kid string, change func(claims map[string]interface{})) string {
+		claims := map[string]interface{}{
+			"aud":                "app",
+			"exp":                time.Now().Add(time.Hour).Unix(),
+			"iat":                time.Now().Unix(),
+			"iss":                srv.URL + "/tid/v2.0",
+			"name":               "Some One",
+			"nonce":              "nonce",
+			"oid":                "oid",
+			"preferred_username": "someone@example.com",
+			"tid":                "tid",
+		}
+		if change != nil {
+			change(claims)
+		}
+		token, err := signJWT(cert.Key, SigningAlgorithmRS256, map[string]interface{}{"kid": kid}, claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	tamper := func(token string) string {
+		b := []byte(token)
+		i := len(b) - 10
+		if b[i] == 'A' {
+			b[i] = 'B'
+		} else {
+			b[i] = 'A'
+		}
+		return string(b)
+	}
+	var keys keySet
+	claims, err := validateIDToken(context.Background(), config, &keys, TenantCommon, "app", "nonce", sign("key", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.ObjectID != "oid" || claims.TenantID != "tid" || claims.PreferredUsername != "someone@example.com" || claims.Name != "Some One" {
+		t.Fatalf("unexpected claims %+v", claims)
+	}
+	invalid := map[string]string{
+		"audience":    sign("key", func(c map[string]interface{}) { c["aud"] = "other" }),
+		"nonce":       sign("key", func(c map[string]interface{}) { c["nonce"] = "other" }),
+		"expiry":      sign("key", func(c map[string]interface{}) { c["exp"] = time.Now().Add(-time.Hour).Unix() }),
+		"issuer":      sign("key", func(c map[string]interface{}) { c["iss"] = "https://evil.example.com/tid/v2.0" }),
+		"signing key": sign("other", nil),
+		"signature":   tamper(sign("key", nil)),
+	}
+	for name, token := range invalid {
+		if _, err := validateIDToken(context.Background(), config, &keys, TenantCommon, "app", "nonce", token); err == nil {
+			t.Errorf("expected a token with a bad %v to be rejected", name)
+		}
+	}
+	if _, err := validateIDToken(context.Background(), config, &keys, "00000000-0000-0000-0000-000000000000", "app", "nonce", sign("key", nil)); err == nil {
+		t.Error("expected a token from another tenant to be rejected")
+	}
+}
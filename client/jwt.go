@@ -0,0 +1,94 @@
+package client
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	// SigningAlgorithmRS256 is RSASSA-PKCS1-v1_5 using SHA-256.
+	SigningAlgorithmRS256 SigningAlgorithm = "RS256"
+	// SigningAlgorithmPS256 is RSASSA-PSS using SHA-256.
+	SigningAlgorithmPS256 SigningAlgorithm = "PS256"
+)
+
+// SigningAlgorithm is the JWS algorithm used to sign or verify a JWT.
+type SigningAlgorithm string
+
+// decodeJWT splits a compact JWT into its decoded header, its raw claims, the input its signature
+// covers and the signature itself. It doesn't verify anything.
+func decodeJWT(token string) (header map[string]interface{}, claims []byte, signingInput string, signature []byte, err error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, nil, "", nil, errors.New("malformed jwt: expected three segments")
+	}
+	hb, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, nil, "", nil, fmt.Errorf("malformed jwt header: %v", err)
+	}
+	if err := json.Unmarshal(hb, &header); err != nil {
+		return nil, nil, "", nil, fmt.Errorf("malformed jwt header: %v", err)
+	}
+	claims, err = base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, nil, "", nil, fmt.Errorf("malformed jwt claims: %v", err)
+	}
+	signature, err = base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, nil, "", nil, fmt.Errorf("malformed jwt signature: %v", err)
+	}
+	return header, claims, parts[0] + "." + parts[1], signature, nil
+}
+
+// verifyJWT checks the signature of a JWT decoded by decodeJWT against the given key.
+func verifyJWT(key *rsa.PublicKey, alg SigningAlgorithm, signingInput string, signature []byte) error {
+	digest := sha256.Sum256([]byte(signingInput))
+	switch alg {
+	case SigningAlgorithmRS256:
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature)
+	case SigningAlgorithmPS256:
+		return rsa.VerifyPSS(key, crypto.SHA256, digest[:], signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+	default:
+		return fmt.Errorf("unsupported signing algorithm %v", alg)
+	}
+}
+
+// signJWT encodes and signs a JWT with the given header and claims. The alg header is filled in
+// from the algorithm.
+func signJWT(key crypto.Signer, alg SigningAlgorithm, header map[string]interface{}, claims interface{}) (string, error) {
+	h := map[string]interface{}{"alg": string(alg), "typ": "JWT"}
+	for k, v := range header {
+		h[k] = v
+	}
+	hb, err := json.Marshal(h)
+	if err != nil {
+		return "", err
+	}
+	cb, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(hb) + "." + base64.RawURLEncoding.EncodeToString(cb)
+	digest := sha256.Sum256([]byte(signingInput))
+	var opts crypto.SignerOpts
+	switch alg {
+	case SigningAlgorithmRS256:
+		opts = crypto.SHA256
+	case SigningAlgorithmPS256:
+		opts = &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256}
+	default:
+		return "", fmt.Errorf("unsupported signing algorithm %v", alg)
+	}
+	sig, err := key.Sign(rand.Reader, digest[:], opts)
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
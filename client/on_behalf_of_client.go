@@ -0,0 +1,187 @@
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/mhoc/msgoraph/scopes"
+)
+
+// jwtBearerGrantType is the grant_type of the on-behalf-of exchange.
+const jwtBearerGrantType = "urn:ietf:params:oauth:grant-type:jwt-bearer"
+
+// OnBehalfOf is used by a middle-tier API to call the Graph API as the user who called it. The
+// API exchanges the access token it received from its own client, the Assertion, for a Graph API
+// token through the OAuth2 on-behalf-of flow, authenticating with its ApplicationSecret or
+// Certificate. See
+// https://docs.microsoft.com/en-us/azure/active-directory/develop/v2-oauth2-on-behalf-of-flow.
+//
+// Tokens are cached per assertion in TokenCache, so a client is typically created once with
+// NewOnBehalfOf and WithAssertion is called for every incoming request. Once a token expires, it's
+// refreshed with its refresh token if DelegatedOfflineAccess was requested, or exchanged again
+// while the assertion is still valid.
+type OnBehalfOf struct {
+	ApplicationID      string
+	ApplicationSecret  string
+	Assertion          string
+	Certificate        *ClientCertificate
+	Config             *Config
+	Error              error
+	RefreshToken       string
+	RequestCredentials *RequestCredentials
+	Scopes             scopes.Scopes
+	TenantID           string
+	TokenCache         TokenCache
+}
+
+// NewOnBehalfOf creates a new client.OnBehalfOf for the given application, caching tokens in
+// memory. Call WithAssertion to get a client for a particular user.
+func NewOnBehalfOf(tenantID string, applicationID string, applicationSecret string, scopes scopes.Scopes) *OnBehalfOf {
+	return &OnBehalfOf{
+		ApplicationID:      applicationID,
+		ApplicationSecret:  applicationSecret,
+		RequestCredentials: &RequestCredentials{},
+		Scopes:             scopes,
+		TenantID:           tenantID,
+		TokenCache:         NewMemoryTokenCache(),
+	}
+}
+
+// WithAssertion returns a copy of this client acting as the user the given access token was issued
+// to. The copy has its own credentials but shares the configuration and token cache of this one.
+func (o *OnBehalfOf) WithAssertion(assertion string) *OnBehalfOf {
+	return &OnBehalfOf{
+		ApplicationID:      o.ApplicationID,
+		ApplicationSecret:  o.ApplicationSecret,
+		Assertion:          assertion,
+		Certificate:        o.Certificate,
+		Config:             o.Config,
+		RequestCredentials: &RequestCredentials{},
+		Scopes:             o.Scopes,
+		TenantID:           o.TenantID,
+		TokenCache:         o.TokenCache,
+	}
+}
+
+// Configuration returns the endpoint and transport configuration of this client. Conforms to the
+// client.Configurable interface.
+func (o *OnBehalfOf) Configuration() *Config {
+	return o.Config
+}
+
+// Credentials returns back the set of request credentials in this client. Conforms to the
+// client.Client interface.
+func (o *OnBehalfOf) Credentials() *RequestCredentials {
+	return o.RequestCredentials
+}
+
+// InitializeCredentials gets a token for the user of the assertion, from the token cache if
+// possible and through the on-behalf-of exchange otherwise.
+func (o *OnBehalfOf) InitializeCredentials() error {
+	return o.InitializeCredentialsContext(context.Background())
+}
+
+// InitializeCredentialsContext is InitializeCredentials, with the token requests bound to the
+// given context.
+func (o *OnBehalfOf) InitializeCredentialsContext(ctx context.Context) error {
+	return o.RequestCredentials.refresh(ctx, o.Config.TokenExpirySkew(), o.fetchToken)
+}
+
+// fetchToken gets a token for the user of the assertion, from the token cache, by redeeming the
+// cached refresh token, or by exchanging the assertion, in that order of preference.
+func (o *OnBehalfOf) fetchToken(ctx context.Context) (string, time.Time, error) {
+	if o.Assertion == "" {
+		return "", time.Time{}, errors.New("client.OnBehalfOf: no user assertion set. call WithAssertion with the access token your api received")
+	}
+	if o.TokenCache != nil {
+		cached, err := o.TokenCache.Load(o.tokenCacheKey())
+		if err != nil {
+			return "", time.Time{}, fmt.Errorf("client.OnBehalfOf: loading token cache: %v", err)
+		}
+		if cached != nil {
+			if cached.AccessToken != "" && cached.AccessTokenExpiresAt.After(time.Now().Add(o.Config.TokenExpirySkew())) {
+				o.RefreshToken = cached.RefreshToken
+				return cached.AccessToken, cached.AccessTokenExpiresAt, nil
+			}
+			if cached.RefreshToken != "" {
+				o.RefreshToken = cached.RefreshToken
+			}
+		}
+	}
+	tokenURI := tokenURL(o.Config, delegatedTenant(o.TenantID))
+	var token *tokenResponse
+	if o.RefreshToken != "" {
+		form := url.Values{
+			"client_id":     {o.ApplicationID},
+			"grant_type":    {"refresh_token"},
+			"refresh_token": {o.RefreshToken},
+			"scope":         {o.Scopes.QueryString()},
+		}
+		err := setClientAuthentication(form, tokenURI, o.ApplicationID, o.ApplicationSecret, o.Certificate)
+		if err != nil {
+			return "", time.Time{}, err
+		}
+		token, err = requestToken(ctx, o.Config, tokenURI, form, true)
+		var tokenErr *TokenError
+		if err != nil && !errors.As(err, &tokenErr) {
+			return "", time.Time{}, err
+		}
+		// A refresh token which is no longer accepted falls back to exchanging the assertion again.
+	}
+	if token == nil {
+		form := url.Values{
+			"assertion":           {o.Assertion},
+			"client_id":           {o.ApplicationID},
+			"grant_type":          {jwtBearerGrantType},
+			"requested_token_use": {"on_behalf_of"},
+			"scope":               {o.Scopes.QueryString()},
+		}
+		err := setClientAuthentication(form, tokenURI, o.ApplicationID, o.ApplicationSecret, o.Certificate)
+		if err != nil {
+			return "", time.Time{}, err
+		}
+		token, err = requestToken(ctx, o.Config, tokenURI, form, o.Scopes.HasScope(scopes.DelegatedOfflineAccess))
+		if err != nil {
+			return "", time.Time{}, err
+		}
+	}
+	if token.RefreshToken != "" {
+		o.RefreshToken = token.RefreshToken
+	}
+	if o.TokenCache != nil {
+		err := o.TokenCache.Save(o.tokenCacheKey(), &CachedToken{
+			AccessToken:          token.AccessToken,
+			AccessTokenExpiresAt: token.ExpiresAt,
+			RefreshToken:         o.RefreshToken,
+		})
+		if err != nil {
+			return "", time.Time{}, fmt.Errorf("client.OnBehalfOf: saving token cache: %v", err)
+		}
+	}
+	return token.AccessToken, token.ExpiresAt, nil
+}
+
+// RefreshCredentials gets a new token for the user of the assertion once the current one has
+// expired. This just proxies through to InitializeCredentials, which refreshes or exchanges the
+// assertion as needed.
+func (o *OnBehalfOf) RefreshCredentials() error {
+	return o.RefreshCredentialsContext(context.Background())
+}
+
+// RefreshCredentialsContext is RefreshCredentials, with the token requests bound to the given
+// context.
+func (o *OnBehalfOf) RefreshCredentialsContext(ctx context.Context) error {
+	return o.InitializeCredentialsContext(ctx)
+}
+
+// tokenCacheKey returns the key the tokens of this client are cached under. The account is a hash
+// of the assertion, which keeps the user's token out of the cache while keeping users apart.
+func (o *OnBehalfOf) tokenCacheKey() TokenCacheKey {
+	sum := sha256.Sum256([]byte(o.Assertion))
+	return newTokenCacheKey(o.ApplicationID, delegatedTenant(o.TenantID), hex.EncodeToString(sum[:]), o.Scopes)
+}
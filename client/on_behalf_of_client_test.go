@@ -0,0 +1,52 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mhoc/msgoraph/scopes"
+)
+
+func TestOnBehalfOfClientCachesPerAssertion(t *testing.T) {
+	exchanged := map[string]int{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Fatal(err)
+		}
+		if got := r.PostForm.Get("grant_type"); got != jwtBearerGrantType {
+			t.Errorf("unexpected grant_type %v", got)
+		}
+		if got := r.PostForm.Get("requested_token_use"); got != "on_behalf_of" {
+			t.Errorf("unexpected requested_token_use %v", got)
+		}
+		if got := r.PostForm.Get("client_secret"); got != "secret" {
+			t.Errorf("unexpected client_secret %v", got)
+		}
+		assertion := r.PostForm.Get("assertion")
+		exchanged[assertion]++
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "graph-" + assertion,
+			"expires_in":   3600,
+		})
+	}))
+	defer srv.Close()
+	api := NewOnBehalfOf("tenant", "app", "secret", scopes.Scopes{scopes.DelegatedUserRead})
+	api.Config = &Config{AuthorityHost: srv.URL, HTTPClient: srv.Client()}
+	for _, assertion := range []string{"alice", "bob", "alice"} {
+		c := api.WithAssertion(assertion)
+		if err := c.InitializeCredentials(); err != nil {
+			t.Fatal(err)
+		}
+		if got := c.Credentials().AccessToken; got != "graph-"+assertion {
+			t.Fatalf("expected the token of %v, got %v", assertion, got)
+		}
+	}
+	if exchanged["alice"] != 1 || exchanged["bob"] != 1 {
+		t.Fatalf("expected one exchange per assertion, got %v", exchanged)
+	}
+	if err := api.InitializeCredentials(); err == nil {
+		t.Fatal("expected a client without an assertion to fail")
+	}
+}
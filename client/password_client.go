@@ -0,0 +1,99 @@
+package client
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	"github.com/mhoc/msgoraph/scopes"
+)
+
+// Password is used to authenticate requests on behalf of a user with their user name and password,
+// through the OAuth resource owner password credentials grant. It skips any interactive sign-in,
+// which makes it suitable for automated tests against test tenants, but it doesn't work with
+// personal accounts, accounts requiring multi-factor authentication or federated accounts, and
+// Microsoft recommends against it everywhere else. TenantID defaults to TenantOrganizations. The
+// ApplicationSecret is only needed for apps registered as confidential clients. See
+// https://docs.microsoft.com/en-us/azure/active-directory/develop/v2-oauth-ropc.
+type Password struct {
+	ApplicationID      string
+	ApplicationSecret  string
+	Config             *Config
+	Error              error
+	Password           string
+	RequestCredentials *RequestCredentials
+	Scopes             scopes.Scopes
+	TenantID           string
+	Username           string
+}
+
+// NewPassword creates a new client.Password connection for the given user. To initialize the
+// authentication on this, call InitializeCredentials.
+func NewPassword(tenantID string, applicationID string, username string, password string, scopes scopes.Scopes) *Password {
+	return &Password{
+		ApplicationID:      applicationID,
+		Password:           password,
+		RequestCredentials: &RequestCredentials{},
+		Scopes:             scopes,
+		TenantID:           tenantID,
+		Username:           username,
+	}
+}
+
+// Configuration returns the endpoint and transport configuration of this client. Conforms to the
+// client.Configurable interface.
+func (p *Password) Configuration() *Config {
+	return p.Config
+}
+
+// Credentials returns back the set of request credentials in this client. Conforms to the
+// client.Client interface.
+func (p *Password) Credentials() *RequestCredentials {
+	return p.RequestCredentials
+}
+
+// InitializeCredentials requests a token with the user name and password of this client.
+func (p *Password) InitializeCredentials() error {
+	return p.InitializeCredentialsContext(context.Background())
+}
+
+// InitializeCredentialsContext is InitializeCredentials, with the token request bound to the given
+// context.
+func (p *Password) InitializeCredentialsContext(ctx context.Context) error {
+	return p.RequestCredentials.refresh(ctx, p.Config.TokenExpirySkew(), p.fetchToken)
+}
+
+// fetchToken requests a token with the user name and password of this client.
+func (p *Password) fetchToken(ctx context.Context) (string, time.Time, error) {
+	tenant := p.TenantID
+	if tenant == "" {
+		tenant = TenantOrganizations
+	}
+	form := url.Values{
+		"client_id":  {p.ApplicationID},
+		"grant_type": {"password"},
+		"password":   {p.Password},
+		"scope":      {p.Scopes.QueryString()},
+		"username":   {p.Username},
+	}
+	if p.ApplicationSecret != "" {
+		form.Set("client_secret", p.ApplicationSecret)
+	}
+	token, err := requestToken(ctx, p.Config, tokenURL(p.Config, tenant), form, false)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token.AccessToken, token.ExpiresAt, nil
+}
+
+// RefreshCredentials will request a new token once the current one has expired. This just proxies
+// through to InitializeCredentials, as the client still holds the user's password.
+func (p *Password) RefreshCredentials() error {
+	return p.RefreshCredentialsContext(context.Background())
+}
+
+// RefreshCredentialsContext is RefreshCredentials, with the token request bound to the given
+// context.
+func (p *Password) RefreshCredentialsContext(ctx context.Context) error {
+	return p.InitializeCredentialsContext(ctx)
+}
@@ -0,0 +1,68 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mhoc/msgoraph/scopes"
+)
+
+func TestPasswordClient(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Fatal(err)
+		}
+		if r.URL.Path != "/organizations/oauth2/v2.0/token" {
+			t.Errorf("unexpected token path %v", r.URL.Path)
+		}
+		expected := map[string]string{
+			"client_id":  "app",
+			"grant_type": "password",
+			"password":   "hunter2",
+			"scope":      scopes.Scopes{scopes.DelegatedUserRead}.QueryString(),
+			"username":   "alice@contoso.com",
+		}
+		for k, v := range expected {
+			if got := r.PostForm.Get(k); got != v {
+				t.Errorf("unexpected %v %q", k, got)
+			}
+		}
+		if _, ok := r.PostForm["client_secret"]; ok {
+			t.Error("public clients should not send a client_secret")
+		}
+		requests++
+		// The first token expires within the expiry skew, so that refreshing requests another.
+		expiresIn := 60
+		if requests > 1 {
+			expiresIn = 3600
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": fmt.Sprintf("token%v", requests),
+			"expires_in":   expiresIn,
+		})
+	}))
+	defer srv.Close()
+	c := NewPassword("", "app", "alice@contoso.com", "hunter2", scopes.Scopes{scopes.DelegatedUserRead})
+	c.Config = &Config{AuthorityHost: srv.URL, HTTPClient: srv.Client()}
+	if err := c.InitializeCredentials(); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Credentials().AccessToken; got != "token1" {
+		t.Fatalf("expected the first token, got %q", got)
+	}
+	for i := 0; i < 2; i++ {
+		if err := c.RefreshCredentials(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := c.Credentials().AccessToken; got != "token2" {
+		t.Fatalf("expected the refreshed token, got %q", got)
+	}
+	if requests != 2 {
+		t.Fatalf("expected a valid token not to be requested again, got %v requests", requests)
+	}
+}
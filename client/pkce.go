@@ -0,0 +1,34 @@
+package client
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+)
+
+// randomToken returns a url-safe string carrying n bytes of cryptographically secure randomness,
+// for use as oauth state, nonces and PKCE verifiers.
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// newPKCE generates a PKCE code verifier and its S256 code challenge (RFC 7636). The verifier is
+// kept by the client and sent with the token request, while the challenge is sent with the
+// authorization request, so that an intercepted authorization code is useless on its own.
+func newPKCE() (verifier string, challenge string, err error) {
+	verifier, err = randomToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	return verifier, pkceChallenge(verifier), nil
+}
+
+// pkceChallenge returns the S256 code challenge of a code verifier.
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,29 @@
+package client
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"testing"
+)
+
+func TestPKCE(t *testing.T) {
+	verifier, challenge, err := newPKCE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// RFC 7636 requires verifiers of 43 to 128 characters.
+	if len(verifier) < 43 || len(verifier) > 128 {
+		t.Errorf("verifier length %v out of range", len(verifier))
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	if want := base64.RawURLEncoding.EncodeToString(sum[:]); challenge != want {
+		t.Errorf("challenge = %v, want %v", challenge, want)
+	}
+	other, _, err := newPKCE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == verifier {
+		t.Error("verifiers should be random")
+	}
+}
@@ -0,0 +1,399 @@
+package client
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"hash"
+	"math/big"
+	"unicode/utf16"
+)
+
+// This file implements just enough of PKCS#12 (RFC 7292) to read the certificate and private key
+// out of the .pfx files Azure and OpenSSL produce, so that certificate credentials don't require a
+// dependency outside of the standard library. It supports PBES2 with AES (the OpenSSL 3 and
+// Windows default) and the legacy pbeWithSHAAnd3-KeyTripleDES-CBC scheme; files encrypted with
+// RC2 have to be re-exported.
+
+var (
+	oidData                 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidEncryptedData        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 6}
+	oidKeyBag               = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 1}
+	oidPKCS8ShroudedKeyBag  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 2}
+	oidCertBag              = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 3}
+	oidX509Certificate      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 22, 1}
+	oidPBEWithSHAAnd3DES    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 3}
+	oidPBEWithSHAAnd40RC2   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 6}
+	oidPBES2                = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2               = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACWithSHA1         = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACWithSHA256       = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidSHA1                 = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidSHA256               = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidAES128CBC            = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC            = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC            = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+	errPKCS12WrongPassword  = errors.New("pkcs12: decryption password incorrect")
+	errPKCS12RC2Unsupported = errors.New("pkcs12: RC2 encryption is not supported, re-export the file with AES or 3DES encryption")
+)
+
+type pfxPdu struct {
+	Version  int
+	AuthSafe contentInfo
+	MacData  macData `asn1:"optional"`
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"tag:0,explicit,optional"`
+}
+
+type encryptedData struct {
+	Version              int
+	EncryptedContentInfo encryptedContentInfo
+}
+
+type encryptedContentInfo struct {
+	ContentType                asn1.ObjectIdentifier
+	ContentEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedContent           []byte `asn1:"tag:0,optional"`
+}
+
+type safeBag struct {
+	ID         asn1.ObjectIdentifier
+	Value      asn1.RawValue     `asn1:"tag:0,explicit"`
+	Attributes []pkcs12Attribute `asn1:"set,optional"`
+}
+
+type pkcs12Attribute struct {
+	ID    asn1.ObjectIdentifier
+	Value asn1.RawValue `asn1:"set"`
+}
+
+type certBag struct {
+	ID   asn1.ObjectIdentifier
+	Data []byte `asn1:"tag:0,explicit"`
+}
+
+type encryptedPrivateKeyInfo struct {
+	Algorithm     pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+type macData struct {
+	Mac        digestInfo
+	MacSalt    []byte
+	Iterations int `asn1:"optional,default:1"`
+}
+
+type digestInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	Digest    []byte
+}
+
+type pbeParams struct {
+	Salt       []byte
+	Iterations int
+}
+
+type pbes2Params struct {
+	Kdf              pkix.AlgorithmIdentifier
+	EncryptionScheme pkix.AlgorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt       asn1.RawValue
+	Iterations int
+	KeyLength  int                      `asn1:"optional"`
+	Prf        pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+// decodePKCS12 returns the private key and every certificate stored in a PKCS#12 file.
+func decodePKCS12(pfxData []byte, password string) (interface{}, []*x509.Certificate, error) {
+	var pfx pfxPdu
+	rest, err := asn1.Unmarshal(pfxData, &pfx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("pkcs12: %v", err)
+	}
+	if len(rest) != 0 {
+		return nil, nil, errors.New("pkcs12: trailing data found")
+	}
+	if pfx.Version != 3 {
+		return nil, nil, fmt.Errorf("pkcs12: unsupported version %v", pfx.Version)
+	}
+	if !pfx.AuthSafe.ContentType.Equal(oidData) {
+		return nil, nil, errors.New("pkcs12: only password-protected files are supported")
+	}
+	var authSafe []byte
+	if _, err := asn1.Unmarshal(pfx.AuthSafe.Content.Bytes, &authSafe); err != nil {
+		return nil, nil, fmt.Errorf("pkcs12: %v", err)
+	}
+	if len(pfx.MacData.Mac.Algorithm.Algorithm) > 0 {
+		if err := verifyPKCS12Mac(&pfx.MacData, authSafe, bmpPassword(password)); err != nil {
+			return nil, nil, err
+		}
+	}
+	var contents []contentInfo
+	if _, err := asn1.Unmarshal(authSafe, &contents); err != nil {
+		return nil, nil, fmt.Errorf("pkcs12: %v", err)
+	}
+	var key interface{}
+	var certs []*x509.Certificate
+	for _, ci := range contents {
+		var data []byte
+		switch {
+		case ci.ContentType.Equal(oidData):
+			if _, err := asn1.Unmarshal(ci.Content.Bytes, &data); err != nil {
+				return nil, nil, fmt.Errorf("pkcs12: %v", err)
+			}
+		case ci.ContentType.Equal(oidEncryptedData):
+			var ed encryptedData
+			if _, err := asn1.Unmarshal(ci.Content.Bytes, &ed); err != nil {
+				return nil, nil, fmt.Errorf("pkcs12: %v", err)
+			}
+			data, err = pbDecrypt(ed.EncryptedContentInfo.ContentEncryptionAlgorithm, ed.EncryptedContentInfo.EncryptedContent, password)
+			if err != nil {
+				return nil, nil, err
+			}
+		default:
+			return nil, nil, fmt.Errorf("pkcs12: unsupported content type %v", ci.ContentType)
+		}
+		var bags []safeBag
+		if _, err := asn1.Unmarshal(data, &bags); err != nil {
+			return nil, nil, fmt.Errorf("pkcs12: %v", err)
+		}
+		for _, bag := range bags {
+			switch {
+			case bag.ID.Equal(oidCertBag):
+				var cb certBag
+				if _, err := asn1.Unmarshal(bag.Value.Bytes, &cb); err != nil {
+					return nil, nil, fmt.Errorf("pkcs12: %v", err)
+				}
+				if !cb.ID.Equal(oidX509Certificate) {
+					continue
+				}
+				cert, err := x509.ParseCertificate(cb.Data)
+				if err != nil {
+					return nil, nil, err
+				}
+				certs = append(certs, cert)
+			case bag.ID.Equal(oidKeyBag):
+				key, err = x509.ParsePKCS8PrivateKey(bag.Value.Bytes)
+				if err != nil {
+					return nil, nil, err
+				}
+			case bag.ID.Equal(oidPKCS8ShroudedKeyBag):
+				var epki encryptedPrivateKeyInfo
+				if _, err := asn1.Unmarshal(bag.Value.Bytes, &epki); err != nil {
+					return nil, nil, fmt.Errorf("pkcs12: %v", err)
+				}
+				pkcs8, err := pbDecrypt(epki.Algorithm, epki.EncryptedData, password)
+				if err != nil {
+					return nil, nil, err
+				}
+				key, err = x509.ParsePKCS8PrivateKey(pkcs8)
+				if err != nil {
+					return nil, nil, err
+				}
+			}
+		}
+	}
+	if key == nil {
+		return nil, nil, errors.New("pkcs12: no private key found")
+	}
+	return key, certs, nil
+}
+
+// verifyPKCS12Mac checks the integrity of the authenticated safe against the file's MAC, which is
+// also the first indication of a wrong password.
+func verifyPKCS12Mac(md *macData, message []byte, password []byte) error {
+	var newHash func() hash.Hash
+	switch {
+	case md.Mac.Algorithm.Algorithm.Equal(oidSHA1):
+		newHash = sha1.New
+	case md.Mac.Algorithm.Algorithm.Equal(oidSHA256):
+		newHash = sha256.New
+	default:
+		return fmt.Errorf("pkcs12: unsupported mac algorithm %v", md.Mac.Algorithm.Algorithm)
+	}
+	key := pkcs12KDF(newHash, password, md.MacSalt, md.Iterations, 3, newHash().Size())
+	mac := hmac.New(newHash, key)
+	mac.Write(message)
+	if !hmac.Equal(mac.Sum(nil), md.Mac.Digest) {
+		return errPKCS12WrongPassword
+	}
+	return nil
+}
+
+// pbDecrypt decrypts data with one of the supported password based encryption schemes.
+func pbDecrypt(algorithm pkix.AlgorithmIdentifier, encrypted []byte, password string) ([]byte, error) {
+	var block cipher.Block
+	var iv []byte
+	switch {
+	case algorithm.Algorithm.Equal(oidPBEWithSHAAnd3DES):
+		var params pbeParams
+		if _, err := asn1.Unmarshal(algorithm.Parameters.FullBytes, &params); err != nil {
+			return nil, fmt.Errorf("pkcs12: %v", err)
+		}
+		pw := bmpPassword(password)
+		key := pkcs12KDF(sha1.New, pw, params.Salt, params.Iterations, 1, 24)
+		iv = pkcs12KDF(sha1.New, pw, params.Salt, params.Iterations, 2, 8)
+		var err error
+		block, err = des.NewTripleDESCipher(key)
+		if err != nil {
+			return nil, err
+		}
+	case algorithm.Algorithm.Equal(oidPBES2):
+		var err error
+		block, iv, err = pbes2Cipher(algorithm.Parameters.FullBytes, []byte(password))
+		if err != nil {
+			return nil, err
+		}
+	case algorithm.Algorithm.Equal(oidPBEWithSHAAnd40RC2):
+		return nil, errPKCS12RC2Unsupported
+	default:
+		return nil, fmt.Errorf("pkcs12: unsupported encryption algorithm %v", algorithm.Algorithm)
+	}
+	if len(encrypted) == 0 || len(encrypted)%block.BlockSize() != 0 {
+		return nil, errors.New("pkcs12: encrypted data is not a multiple of the block size")
+	}
+	decrypted := make([]byte, len(encrypted))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(decrypted, encrypted)
+	padding := int(decrypted[len(decrypted)-1])
+	if padding == 0 || padding > block.BlockSize() || padding > len(decrypted) {
+		return nil, errPKCS12WrongPassword
+	}
+	if !bytes.Equal(decrypted[len(decrypted)-padding:], bytes.Repeat([]byte{byte(padding)}, padding)) {
+		return nil, errPKCS12WrongPassword
+	}
+	return decrypted[:len(decrypted)-padding], nil
+}
+
+// pbes2Cipher builds the AES cipher and iv described by PBES2 parameters, deriving the key with
+// PBKDF2.
+func pbes2Cipher(params []byte, password []byte) (cipher.Block, []byte, error) {
+	var p pbes2Params
+	if _, err := asn1.Unmarshal(params, &p); err != nil {
+		return nil, nil, fmt.Errorf("pkcs12: %v", err)
+	}
+	if !p.Kdf.Algorithm.Equal(oidPBKDF2) {
+		return nil, nil, fmt.Errorf("pkcs12: unsupported key derivation function %v", p.Kdf.Algorithm)
+	}
+	var kdf pbkdf2Params
+	if _, err := asn1.Unmarshal(p.Kdf.Parameters.FullBytes, &kdf); err != nil {
+		return nil, nil, fmt.Errorf("pkcs12: %v", err)
+	}
+	var salt []byte
+	if _, err := asn1.Unmarshal(kdf.Salt.FullBytes, &salt); err != nil {
+		return nil, nil, fmt.Errorf("pkcs12: unsupported pbkdf2 salt: %v", err)
+	}
+	newHash := sha1.New
+	if len(kdf.Prf.Algorithm) > 0 {
+		switch {
+		case kdf.Prf.Algorithm.Equal(oidHMACWithSHA1):
+		case kdf.Prf.Algorithm.Equal(oidHMACWithSHA256):
+			newHash = sha256.New
+		default:
+			return nil, nil, fmt.Errorf("pkcs12: unsupported pbkdf2 prf %v", kdf.Prf.Algorithm)
+		}
+	}
+	var keyLen int
+	switch {
+	case p.EncryptionScheme.Algorithm.Equal(oidAES128CBC):
+		keyLen = 16
+	case p.EncryptionScheme.Algorithm.Equal(oidAES192CBC):
+		keyLen = 24
+	case p.EncryptionScheme.Algorithm.Equal(oidAES256CBC):
+		keyLen = 32
+	default:
+		return nil, nil, fmt.Errorf("pkcs12: unsupported encryption scheme %v", p.EncryptionScheme.Algorithm)
+	}
+	var iv []byte
+	if _, err := asn1.Unmarshal(p.EncryptionScheme.Parameters.FullBytes, &iv); err != nil {
+		return nil, nil, fmt.Errorf("pkcs12: %v", err)
+	}
+	key, err := pbkdf2.Key(newHash, string(password), salt, kdf.Iterations, keyLen)
+	if err != nil {
+		return nil, nil, fmt.Errorf("pkcs12: %v", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(iv) != block.BlockSize() {
+		return nil, nil, errors.New("pkcs12: invalid iv length")
+	}
+	return block, iv, nil
+}
+
+// pkcs12KDF derives key material with the PKCS#12 key derivation function (RFC 7292, appendix
+// B.2). The id selects what the material is for: 1 for keys, 2 for ivs and 3 for MAC keys.
+func pkcs12KDF(newHash func() hash.Hash, password []byte, salt []byte, iterations int, id byte, size int) []byte {
+	h := newHash()
+	v := h.BlockSize()
+	d := bytes.Repeat([]byte{id}, v)
+	fill := func(b []byte) []byte {
+		if len(b) == 0 {
+			return nil
+		}
+		out := make([]byte, v*((len(b)+v-1)/v))
+		for i := range out {
+			out[i] = b[i%len(b)]
+		}
+		return out
+	}
+	i := append(fill(salt), fill(password)...)
+	one := big.NewInt(1)
+	modulus := new(big.Int).Lsh(one, uint(v*8))
+	var out []byte
+	for len(out) < size {
+		h.Reset()
+		h.Write(d)
+		h.Write(i)
+		a := h.Sum(nil)
+		for n := 1; n < iterations; n++ {
+			h.Reset()
+			h.Write(a)
+			a = h.Sum(a[:0])
+		}
+		out = append(out, a...)
+		if len(out) >= size {
+			break
+		}
+		b := new(big.Int).SetBytes(fill(a)[:v])
+		b.Add(b, one)
+		for j := 0; j < len(i); j += v {
+			ij := new(big.Int).SetBytes(i[j : j+v])
+			ij.Add(ij, b)
+			ij.Mod(ij, modulus)
+			raw := ij.Bytes()
+			block := i[j : j+v]
+			for k := range block {
+				block[k] = 0
+			}
+			copy(block[v-len(raw):], raw)
+		}
+	}
+	return out[:size]
+}
+
+// bmpPassword encodes a password as a null terminated big-endian UTF-16 string, as the PKCS#12 key
+// derivation function expects.
+func bmpPassword(password string) []byte {
+	encoded := utf16.Encode([]rune(password))
+	b := make([]byte, 0, 2*len(encoded)+2)
+	for _, r := range encoded {
+		b = append(b, byte(r>>8), byte(r))
+	}
+	return append(b, 0, 0)
+}
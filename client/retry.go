@@ -0,0 +1,174 @@
+package client
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy describes how requests which fail because of throttling or transient server errors
+// should be retried. The Graph API responds to throttled requests with a 429, or a 503/504 under
+// heavier load, along with a Retry-After header which the policy always honors when present.
+// See https://docs.microsoft.com/en-us/graph/throttling.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts made for a request, including the first. A value
+	// of 1 or less disables retries.
+	MaxAttempts int
+
+	// MaxElapsed bounds the total time spent on a request across every attempt. No retry is made if
+	// its delay would exceed this budget. Zero means there is no bound.
+	MaxElapsed time.Duration
+
+	// BaseDelay is the delay before the first retry when the response carries no Retry-After
+	// header. It doubles on every following attempt, up to MaxDelay, with random jitter applied.
+	BaseDelay time.Duration
+
+	// MaxDelay caps the exponential backoff delay. It does not cap Retry-After.
+	MaxDelay time.Duration
+
+	// RetryNonIdempotent allows POST and PATCH requests to be replayed after transport errors and
+	// 503/504 responses, where the server may already have acted on them. By default these
+	// requests are only retried after a 429, which guarantees the request was not processed.
+	RetryNonIdempotent bool
+}
+
+// DefaultRetryPolicy returns the retry policy recommended for most applications.
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts: 5,
+		MaxElapsed:  2 * time.Minute,
+		BaseDelay:   time.Second,
+		MaxDelay:    30 * time.Second,
+	}
+}
+
+// RetryTransport is an http.RoundTripper middleware which retries requests according to a
+// RetryPolicy. Set it as the Transport in a client Config, or as the Transport of your own
+// http.Client, to apply it to every request.
+type RetryTransport struct {
+	// Base is the RoundTripper the requests are sent through. http.DefaultTransport is used if nil.
+	Base http.RoundTripper
+
+	// Policy is the retry policy to apply. DefaultRetryPolicy is used if nil.
+	Policy *RetryPolicy
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	policy := t.Policy
+	if policy == nil {
+		policy = DefaultRetryPolicy()
+	}
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		attemptReq := req
+		if attempt > 1 && req.Body != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			attemptReq = req.Clone(req.Context())
+			attemptReq.Body = body
+		}
+		resp, err := base.RoundTrip(attemptReq)
+		if attempt >= policy.MaxAttempts || !policy.shouldRetry(req, resp, err) {
+			return resp, err
+		}
+		delay := policy.delay(attempt, resp)
+		if policy.MaxElapsed > 0 && time.Since(start)+delay > policy.MaxElapsed {
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		if err := sleepContext(req.Context(), delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// shouldRetry decides whether the outcome of an attempt is worth retrying.
+func (p *RetryPolicy) shouldRetry(req *http.Request, resp *http.Response, err error) bool {
+	if req.Body != nil && req.GetBody == nil {
+		return false
+	}
+	if req.Context().Err() != nil {
+		return false
+	}
+	replayable := p.RetryNonIdempotent || isIdempotent(req.Method)
+	if err != nil {
+		return replayable
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return replayable
+	}
+	return false
+}
+
+// delay returns how long to wait before the next attempt, preferring the server's Retry-After.
+func (p *RetryPolicy) delay(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if d, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+			return d
+		}
+	}
+	d := p.BaseDelay << uint(attempt-1)
+	if d <= 0 || (p.MaxDelay > 0 && d > p.MaxDelay) {
+		d = p.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	// Jittering over the upper half of the window keeps most of the backoff while spreading out
+	// retries from concurrent requests.
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// retryAfter parses a Retry-After header, which may either be a number of seconds or an http date.
+func retryAfter(header string) (time.Duration, bool) {
+	if header == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(header); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if at, err := http.ParseTime(header); err == nil {
+		d := time.Until(at)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS", "TRACE", "PUT", "DELETE":
+		return true
+	}
+	return false
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
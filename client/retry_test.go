@@ -0,0 +1,65 @@
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRetryTransport(t *testing.T) {
+	attempts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+	config := &Config{RetryPolicy: &RetryPolicy{MaxAttempts: 5, BaseDelay: time.Millisecond}}
+	resp, err := config.HTTP().Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || attempts != 3 {
+		t.Fatalf("expected success on the third attempt, got %v after %v attempts", resp.StatusCode, attempts)
+	}
+}
+
+func TestRetryTransportNonIdempotent(t *testing.T) {
+	attempts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	config := &Config{RetryPolicy: &RetryPolicy{MaxAttempts: 5, BaseDelay: time.Millisecond}}
+	resp, err := config.HTTP().Post(srv.URL, "application/json", strings.NewReader("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if attempts != 1 {
+		t.Fatalf("expected a POST to not be replayed after a 503, got %v attempts", attempts)
+	}
+}
+
+func TestConfigHTTPIsBuiltOnce(t *testing.T) {
+	base := &http.Client{Timeout: time.Minute}
+	config := &Config{HTTPClient: base, RetryPolicy: &RetryPolicy{MaxAttempts: 2}}
+	httpClient := config.HTTP()
+	if config.HTTP() != httpClient {
+		t.Fatal("expected every call to return the same http client")
+	}
+	if _, ok := httpClient.Transport.(*RetryTransport); !ok || httpClient.Timeout != time.Minute {
+		t.Fatalf("expected a retrying copy of the given client, got %+v", httpClient)
+	}
+	if base.Transport != nil {
+		t.Fatal("expected the given client to be left unchanged")
+	}
+}
@@ -0,0 +1,152 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// tokenResponse is the subset of the oauth2 v2.0 token endpoint response that the clients in this
+// package care about.
+type tokenResponse struct {
+	AccessToken  string
+	ExpiresAt    time.Time
+	IDToken      string
+	RefreshToken string
+}
+
+const (
+	// TenantCommon is the multi-tenant authority, which accepts both work or school and personal
+	// Microsoft accounts. It can only be used for delegated sign-in.
+	TenantCommon = "common"
+	// TenantOrganizations is the multi-tenant authority which only accepts work or school accounts.
+	// It can only be used for delegated sign-in.
+	TenantOrganizations = "organizations"
+	// TenantConsumers is the authority which only accepts personal Microsoft accounts.
+	TenantConsumers = "consumers"
+)
+
+// validateAppOnlyTenant makes sure the tenant identifies a single directory, which is required for
+// the client_credentials grant; Azure AD rejects app-only token requests sent to the multi-tenant
+// authorities.
+func validateAppOnlyTenant(tenant string) error {
+	switch strings.ToLower(tenant) {
+	case "":
+		return errors.New("a tenant id is required to request app-only tokens")
+	case TenantCommon, TenantOrganizations, TenantConsumers:
+		return fmt.Errorf("app-only tokens cannot be requested from the %q authority, provide a tenant id or domain", tenant)
+	}
+	return nil
+}
+
+// delegatedTenant returns the tenant to use for delegated sign-in, which defaults to TenantCommon.
+func delegatedTenant(tenant string) string {
+	if tenant == "" {
+		return TenantCommon
+	}
+	return tenant
+}
+
+// tokenURL returns the v2.0 token endpoint for the given tenant on the configured authority.
+func tokenURL(config *Config, tenant string) string {
+	return fmt.Sprintf("%v%v/oauth2/v2.0/token", config.Authority(), tenant)
+}
+
+// authorizeURL returns the v2.0 authorize endpoint for the given tenant on the configured
+// authority.
+func authorizeURL(config *Config, tenant string) string {
+	return fmt.Sprintf("%v%v/oauth2/v2.0/authorize", config.Authority(), tenant)
+}
+
+// keysURL returns the v2.0 JWKS document listing the keys the given tenant signs tokens with.
+func keysURL(config *Config, tenant string) string {
+	if config != nil && config.JWKSURL != "" {
+		return config.JWKSURL
+	}
+	return fmt.Sprintf("%v%v/discovery/v2.0/keys", config.Authority(), tenant)
+}
+
+// setClientAuthentication adds the confidential client credentials of an application to a token
+// request form: a signed assertion if a certificate is given, the application secret otherwise.
+func setClientAuthentication(form url.Values, tokenURI string, applicationID string, applicationSecret string, certificate *ClientCertificate) error {
+	if certificate != nil {
+		assertion, err := certificate.Assertion(applicationID, tokenURI)
+		if err != nil {
+			return err
+		}
+		form.Set("client_assertion_type", clientAssertionType)
+		form.Set("client_assertion", assertion)
+		return nil
+	}
+	form.Set("client_secret", applicationSecret)
+	return nil
+}
+
+// postForm posts the given form to an Azure AD endpoint over the configured http client and decodes
+// the json response. Error responses are returned as a *TokenError.
+func postForm(ctx context.Context, config *Config, uri string, form url.Values) (map[string]interface{}, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", uri, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := config.HTTP().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var data map[string]interface{}
+	err = json.Unmarshal(b, &data)
+	if err != nil {
+		return nil, err
+	}
+	serverErrCode, ok := data["error"].(string)
+	if ok {
+		serverErr, _ := data["error_description"].(string)
+		return nil, &TokenError{
+			StatusCode:  resp.StatusCode,
+			Code:        serverErrCode,
+			Description: serverErr,
+		}
+	}
+	return data, nil
+}
+
+// requestToken posts the given form to the token endpoint over the configured http client and
+// parses the response. If requireRefresh is true, a response without a refresh token is treated as
+// an error.
+func requestToken(ctx context.Context, config *Config, tokenURI string, form url.Values, requireRefresh bool) (*tokenResponse, error) {
+	data, err := postForm(ctx, config, tokenURI, form)
+	if err != nil {
+		return nil, err
+	}
+	accessToken, ok := data["access_token"].(string)
+	if !ok || accessToken == "" {
+		return nil, fmt.Errorf("no access token found in response")
+	}
+	durationSecs, ok := data["expires_in"].(float64)
+	if !ok || durationSecs == 0 {
+		return nil, fmt.Errorf("no token duration found in response")
+	}
+	idToken, _ := data["id_token"].(string)
+	refreshToken, _ := data["refresh_token"].(string)
+	if requireRefresh && refreshToken == "" {
+		return nil, fmt.Errorf("no refresh token found in response")
+	}
+	return &tokenResponse{
+		AccessToken:  accessToken,
+		ExpiresAt:    time.Now().Add(time.Duration(durationSecs) * time.Second),
+		IDToken:      idToken,
+		RefreshToken: refreshToken,
+	}, nil
+}
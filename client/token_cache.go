@@ -0,0 +1,201 @@
+package client
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mhoc/msgoraph/scopes"
+)
+
+// TokenCache persists tokens between runs of a process, so that clients can reuse a previous
+// sign-in instead of starting a new one. Clients consult the cache set on their TokenCache field
+// before requesting a token, and save every token they receive to it.
+type TokenCache interface {
+	// Load returns the token saved under the given key, or nil if there is none.
+	Load(key TokenCacheKey) (*CachedToken, error)
+	// Save stores the token under the given key, replacing any token saved before.
+	Save(key TokenCacheKey, token *CachedToken) error
+}
+
+// TokenCacheKey identifies the tokens of one application, tenant, account and set of scopes.
+type TokenCacheKey struct {
+	// ApplicationID is the id of the application the token was issued to.
+	ApplicationID string
+	// TenantID is the tenant the token was requested from.
+	TenantID string
+	// Account is the account the token was issued for. It's empty for app-only tokens.
+	Account string
+	// Scopes is the space separated, sorted list of scopes the token was requested with.
+	Scopes string
+}
+
+// String returns the key in a form usable as a map or file key.
+func (k TokenCacheKey) String() string {
+	return strings.Join([]string{k.ApplicationID, k.TenantID, k.Account, k.Scopes}, "|")
+}
+
+// CachedToken is a token as stored in a TokenCache.
+type CachedToken struct {
+	AccessToken          string    `json:"accessToken"`
+	AccessTokenExpiresAt time.Time `json:"accessTokenExpiresAt"`
+	RefreshToken         string    `json:"refreshToken,omitempty"`
+}
+
+// newTokenCacheKey builds a cache key, normalizing the scopes so that their order doesn't matter.
+func newTokenCacheKey(applicationID string, tenantID string, account string, s scopes.Scopes) TokenCacheKey {
+	perms := strings.Fields(s.QueryString())
+	for i := range perms {
+		perms[i] = strings.ToLower(perms[i])
+	}
+	sort.Strings(perms)
+	return TokenCacheKey{
+		ApplicationID: applicationID,
+		TenantID:      strings.ToLower(tenantID),
+		Account:       strings.ToLower(account),
+		Scopes:        strings.Join(perms, " "),
+	}
+}
+
+// MemoryTokenCache is a TokenCache which only lives as long as the process. It's safe for
+// concurrent use, so several clients can share one.
+type MemoryTokenCache struct {
+	lock   sync.Mutex
+	tokens map[string]CachedToken
+}
+
+// NewMemoryTokenCache creates an empty in-memory token cache.
+func NewMemoryTokenCache() *MemoryTokenCache {
+	return &MemoryTokenCache{tokens: map[string]CachedToken{}}
+}
+
+// Load returns the token saved under the given key, or nil if there is none.
+func (c *MemoryTokenCache) Load(key TokenCacheKey) (*CachedToken, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	token, ok := c.tokens[key.String()]
+	if !ok {
+		return nil, nil
+	}
+	return &token, nil
+}
+
+// Save stores the token under the given key.
+func (c *MemoryTokenCache) Save(key TokenCacheKey, token *CachedToken) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.tokens[key.String()] = *token
+	return nil
+}
+
+// FileTokenCache is a TokenCache stored in a single file, encrypted with AES-GCM so that the
+// refresh tokens in it are useless without the key. The key should come from somewhere other than
+// the file system the cache lives on, such as the operating system keychain. It's safe for
+// concurrent use within a process, but not across processes sharing the file.
+type FileTokenCache struct {
+	aead cipher.AEAD
+	lock sync.Mutex
+	path string
+}
+
+// NewFileTokenCache creates a token cache stored at the given path and encrypted with the given
+// AES key, which must be 16, 24 or 32 bytes long. The file is created on the first save.
+func NewFileTokenCache(path string, key []byte) (*FileTokenCache, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &FileTokenCache{aead: aead, path: path}, nil
+}
+
+// Load returns the token saved under the given key, or nil if there is none.
+func (c *FileTokenCache) Load(key TokenCacheKey) (*CachedToken, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	tokens, err := c.read()
+	if err != nil {
+		return nil, err
+	}
+	token, ok := tokens[key.String()]
+	if !ok {
+		return nil, nil
+	}
+	return &token, nil
+}
+
+// Save stores the token under the given key, rewriting the whole file.
+func (c *FileTokenCache) Save(key TokenCacheKey, token *CachedToken) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	tokens, err := c.read()
+	if err != nil {
+		return err
+	}
+	tokens[key.String()] = *token
+	return c.write(tokens)
+}
+
+// read decrypts the cache file. A missing file is an empty cache.
+func (c *FileTokenCache) read() (map[string]CachedToken, error) {
+	tokens := map[string]CachedToken{}
+	data, err := ioutil.ReadFile(c.path)
+	if os.IsNotExist(err) {
+		return tokens, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	nonceSize := c.aead.NonceSize()
+	if len(data) < nonceSize {
+		return nil, errors.New("token cache file is truncated")
+	}
+	plain, err := c.aead.Open(nil, data[:nonceSize], data[nonceSize:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not decrypt token cache file, it may have been written with another key: %v", err)
+	}
+	if err := json.Unmarshal(plain, &tokens); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// write encrypts the tokens under a fresh nonce and replaces the cache file with them. The file is
+// written next to the old one and renamed over it, so a crash never leaves a half written cache.
+func (c *FileTokenCache) write(tokens map[string]CachedToken) error {
+	plain, err := json.Marshal(tokens)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	data := c.aead.Seal(nonce, nonce, plain, nil)
+	tmp, err := ioutil.TempFile(filepath.Dir(c.path), filepath.Base(c.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path)
+}
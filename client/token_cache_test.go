@@ -0,0 +1,125 @@
+package client
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mhoc/msgoraph/scopes"
+)
+
+func TestFileTokenCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tokencache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "tokens")
+	key := make([]byte, 32)
+	cache, err := NewFileTokenCache(path, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cacheKey := newTokenCacheKey("app", "tenant", "someone@example.com", scopes.Scopes{scopes.DelegatedUserRead, scopes.DelegatedOfflineAccess})
+	if token, err := cache.Load(cacheKey); err != nil || token != nil {
+		t.Fatalf("expected an empty cache, got %v, %v", token, err)
+	}
+	saved := &CachedToken{AccessToken: "access", AccessTokenExpiresAt: time.Now().Add(time.Hour).UTC(), RefreshToken: "refresh"}
+	if err := cache.Save(cacheKey, saved); err != nil {
+		t.Fatal(err)
+	}
+	// A second cache over the same file sees the token, regardless of the order of the scopes.
+	cache, err = NewFileTokenCache(path, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := cache.Load(newTokenCacheKey("app", "tenant", "someone@example.com", scopes.Scopes{scopes.DelegatedOfflineAccess, scopes.DelegatedUserRead}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token == nil || token.RefreshToken != "refresh" || !token.AccessTokenExpiresAt.Equal(saved.AccessTokenExpiresAt) {
+		t.Fatalf("unexpected cached token %+v", token)
+	}
+	otherKey := make([]byte, 32)
+	otherKey[0] = 1
+	cache, err = NewFileTokenCache(path, otherKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.Load(cacheKey); err == nil {
+		t.Fatal("expected loading with the wrong key to fail")
+	}
+}
+
+func TestHeadlessClientTokenCache(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "token",
+			"expires_in":   3600,
+		})
+	}))
+	defer srv.Close()
+	cache := NewMemoryTokenCache()
+	for i := 0; i < 2; i++ {
+		c := NewHeadless("tenant", "app", "secret", nil)
+		c.Config = &Config{AuthorityHost: srv.URL, HTTPClient: srv.Client()}
+		c.TokenCache = cache
+		if err := c.InitializeCredentials(); err != nil {
+			t.Fatal(err)
+		}
+		if c.Credentials().AccessToken != "token" {
+			t.Fatalf("expected access token to be set, got %q", c.Credentials().AccessToken)
+		}
+	}
+	if requests != 1 {
+		t.Fatalf("expected the cached token to be reused, got %v token requests", requests)
+	}
+}
+
+func TestWebClientTokenCache(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Fatal(err)
+		}
+		if got := r.PostForm.Get("refresh_token"); got != "cached" {
+			t.Errorf("expected the cached refresh token, got %q", got)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token":  "token",
+			"expires_in":    3600,
+			"refresh_token": "rotated",
+		})
+	}))
+	defer srv.Close()
+	cache := NewMemoryTokenCache()
+	c := NewWeb("app", "", 0, scopes.Scopes{scopes.DelegatedUserRead, scopes.DelegatedOfflineAccess})
+	c.Config = &Config{AuthorityHost: srv.URL, HTTPClient: srv.Client()}
+	c.OpenBrowser = func(string) error {
+		t.Fatal("expected the cached refresh token to be used instead of signing in")
+		return nil
+	}
+	c.TokenCache = cache
+	if err := cache.Save(c.tokenCacheKey(), &CachedToken{RefreshToken: "cached"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.InitializeCredentials(); err != nil {
+		t.Fatal(err)
+	}
+	if c.Credentials().AccessToken != "token" {
+		t.Fatalf("expected access token to be set, got %q", c.Credentials().AccessToken)
+	}
+	token, err := cache.Load(c.tokenCacheKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token == nil || token.RefreshToken != "rotated" {
+		t.Fatalf("expected the rotated refresh token to be saved, got %+v", token)
+	}
+}
@@ -0,0 +1,87 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// TokenSourceFunc returns a Graph API access token and the time it expires at. It's called whenever
+// the token held by a client.TokenSource has expired.
+type TokenSourceFunc func(ctx context.Context) (accessToken string, expiresAt time.Time, err error)
+
+// TokenSource is used to authenticate requests with access tokens obtained outside this package,
+// such as tokens minted by another system or handed out by a test harness. The Source function is
+// asked for a new token once the current one is within the configured expiry skew of expiring;
+// set a negative Config.ExpirySkew to use tokens right up to their expiry.
+type TokenSource struct {
+	Config             *Config
+	Error              error
+	RequestCredentials *RequestCredentials
+	Source             TokenSourceFunc
+}
+
+// NewTokenSource creates a new client.TokenSource which gets its tokens from the given function.
+func NewTokenSource(source TokenSourceFunc) *TokenSource {
+	return &TokenSource{
+		RequestCredentials: &RequestCredentials{},
+		Source:             source,
+	}
+}
+
+// staticTokenNoExpiry is the expiry given to static tokens created without one, so that they stay
+// valid rather than being asked for again before every request.
+var staticTokenNoExpiry = time.Date(9999, time.December, 31, 0, 0, 0, 0, time.UTC)
+
+// NewStaticToken creates a new client.TokenSource which always uses the given access token. A zero
+// expiresAt means the token is used for as long as the Graph API accepts it.
+func NewStaticToken(accessToken string, expiresAt time.Time) *TokenSource {
+	return NewTokenSource(func(ctx context.Context) (string, time.Time, error) {
+		if expiresAt.IsZero() {
+			return accessToken, staticTokenNoExpiry, nil
+		}
+		if !expiresAt.After(time.Now()) {
+			return "", time.Time{}, errors.New("client.TokenSource: the static access token has expired")
+		}
+		return accessToken, expiresAt, nil
+	})
+}
+
+// Configuration returns the endpoint and transport configuration of this client. Conforms to the
+// client.Configurable interface.
+func (t *TokenSource) Configuration() *Config {
+	return t.Config
+}
+
+// Credentials returns back the set of request credentials in this client. Conforms to the
+// client.Client interface.
+func (t *TokenSource) Credentials() *RequestCredentials {
+	return t.RequestCredentials
+}
+
+// InitializeCredentials gets a token from the Source function, unless the current one is still
+// valid.
+func (t *TokenSource) InitializeCredentials() error {
+	return t.InitializeCredentialsContext(context.Background())
+}
+
+// InitializeCredentialsContext is InitializeCredentials, passing the given context on to the
+// Source function.
+func (t *TokenSource) InitializeCredentialsContext(ctx context.Context) error {
+	if t.Source == nil {
+		return errors.New("client.TokenSource: no token source function set")
+	}
+	return t.RequestCredentials.refresh(ctx, t.Config.TokenExpirySkew(), t.Source)
+}
+
+// RefreshCredentials gets a new token from the Source function once the current one has expired.
+// This just proxies through to InitializeCredentials.
+func (t *TokenSource) RefreshCredentials() error {
+	return t.RefreshCredentialsContext(context.Background())
+}
+
+// RefreshCredentialsContext is RefreshCredentials, passing the given context on to the Source
+// function.
+func (t *TokenSource) RefreshCredentialsContext(ctx context.Context) error {
+	return t.InitializeCredentialsContext(ctx)
+}
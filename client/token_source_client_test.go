@@ -0,0 +1,80 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTokenSourceClientRefreshesExpiredTokens(t *testing.T) {
+	calls := 0
+	c := NewTokenSource(func(ctx context.Context) (string, time.Time, error) {
+		calls++
+		// The first token is already expired, so the refresh asks for another.
+		if calls == 1 {
+			return "first", time.Now().Add(-time.Minute), nil
+		}
+		return "second", time.Now().Add(time.Hour), nil
+	})
+	if err := c.InitializeCredentials(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := c.RefreshCredentials(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if c.Credentials().AccessToken != "second" || calls != 2 {
+		t.Fatalf("got token %q after %v calls", c.Credentials().AccessToken, calls)
+	}
+	if err := NewStaticToken("static", time.Now().Add(-time.Minute)).InitializeCredentials(); err == nil {
+		t.Fatal("expected an expired static token to be rejected")
+	}
+}
+
+func TestStaticTokenWithoutExpiryIsReused(t *testing.T) {
+	c := NewStaticToken("static", time.Time{})
+	source := c.Source
+	calls := 0
+	c.Source = func(ctx context.Context) (string, time.Time, error) {
+		calls++
+		return source(ctx)
+	}
+	if err := c.InitializeCredentials(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := c.RefreshCredentials(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if c.Credentials().AccessToken != "static" || calls != 1 {
+		t.Fatalf("got token %q after %v calls, expected the source to run once", c.Credentials().AccessToken, calls)
+	}
+}
+
+func TestTokenSourceClientHonorsExpirySkew(t *testing.T) {
+	for _, c := range []struct {
+		skew  time.Duration
+		calls int
+	}{
+		{skew: 0, calls: 2},
+		{skew: -1, calls: 1},
+	} {
+		calls := 0
+		ts := NewTokenSource(func(ctx context.Context) (string, time.Time, error) {
+			calls++
+			return "token", time.Now().Add(time.Minute), nil
+		})
+		ts.Config = &Config{ExpirySkew: c.skew}
+		if err := ts.InitializeCredentials(); err != nil {
+			t.Fatal(err)
+		}
+		if err := ts.RefreshCredentials(); err != nil {
+			t.Fatal(err)
+		}
+		if calls != c.calls {
+			t.Fatalf("skew %v: expected %v calls, got %v", c.skew, c.calls, calls)
+		}
+	}
+}
@@ -2,10 +2,10 @@ package client
 
 import (
 	"context"
-	"encoding/json"
+	"crypto/subtle"
+	"errors"
 	"fmt"
-	"io/ioutil"
-	"log"
+	"net"
 	"net/http"
 	"net/url"
 	"os/exec"
@@ -16,22 +16,69 @@ import (
 	"github.com/mhoc/msgoraph/scopes"
 )
 
+// DefaultLoginTimeout is how long client.Web waits for the user to complete sign-in in the browser
+// when no LoginTimeout is set.
+const DefaultLoginTimeout = 5 * time.Minute
+
 // Web is used to authenticate requests in the context of an online/user-facing app, such
 // as a website. This type of client is mostly useful for debugging or for command line apps where
 // the user configures their own app on the Microsoft Graph portal. In a normal web app, the
 // InitializeCredentials()->setAuthorizationCode() part of this would be called on the client,
-// then the code would be sent to the backend for the setAccessToken() part, given that that part
-// does require an ApplicationSecret. Be sure to specify DelegatedOfflineAccess as a scope if you
-// want refreshing to work.
+// then the code would be sent to the backend for the redeemAuthorizationCode() part, given that
+// that part does require an ApplicationSecret. Be sure to specify DelegatedOfflineAccess as a
+// scope if you want refreshing to work. TenantID restricts sign-in to a single tenant; it defaults
+// to TenantCommon, which accepts accounts from any tenant.
+//
+// Every sign-in uses PKCE and a random state parameter, so the ApplicationSecret can be left empty
+// for apps registered as public clients.
+//
+// During sign-in the client listens for the redirect on http://127.0.0.1:LocalhostPort/login,
+// bound to the loopback interface only, so register that as the redirect uri of the app. The
+// literal address is used rather than localhost, which may resolve to ::1 instead. A LocalhostPort
+// of zero binds a new ephemeral port for every sign-in, which Azure AD accepts for loopback
+// redirect uris registered without a port. The sign-in page is opened with OpenBrowser, which
+// defaults to OpenSystemBrowser, and the client gives up waiting after LoginTimeout, which defaults
+// to DefaultLoginTimeout.
+//
+// Account optionally names the user to sign in, and is passed to the sign-in page as a hint. If
+// TokenCache is set, InitializeCredentials first tries the token cached for the application,
+// tenant, account and scopes, refreshing it if needed, and only starts a browser sign-in if that
+// fails. Every token received is saved back to the cache.
+//
+// When the sign-in includes scopes.DelegatedOpenID, the ID token returned alongside the access
+// token is validated and its claims are made available in IDTokenClaims. Its issuer has to be on
+// the configured authority host, so Azure AD B2C authorities are not supported. Tokens restored
+// from the TokenCache carry no ID token.
 type Web struct {
+	Account            string
 	ApplicationID      string
 	ApplicationSecret  string
 	AuthorizationCode  string
+	Config             *Config
 	Error              error
+	IDToken            string
+	IDTokenClaims      *IDTokenClaims
 	LocalhostPort      int
+	LoginTimeout       time.Duration
+	OpenBrowser        func(uri string) error
 	RefreshToken       string
 	RequestCredentials *RequestCredentials
 	Scopes             scopes.Scopes
+	TenantID           string
+	TokenCache         TokenCache
+
+	// codeVerifier is the PKCE verifier of the sign-in in progress.
+	codeVerifier string
+	// redirectPort is the port the last sign-in listened on, which the authorization code and
+	// refresh token are redeemed with.
+	redirectPort int
+	// keys are the signing keys ID tokens are verified against.
+	keys keySet
+	// nonce is the nonce of the sign-in in progress, which its ID token has to carry.
+	nonce string
+	// state is the oauth state parameter of the sign-in in progress, which the redirect has to echo
+	// back before its code is accepted.
+	state string
 }
 
 // NewWeb creates a new client.Web connection. To initialize the authentication on this, call
@@ -46,6 +93,12 @@ func NewWeb(applicationID string, applicationSecret string, redirectURIPort int,
 	}
 }
 
+// Configuration returns the endpoint and transport configuration of this client. Conforms to the
+// client.Configurable interface.
+func (w *Web) Configuration() *Config {
+	return w.Config
+}
+
 // Credentials returns back the set of request credentials in this client. Conforms to the
 // client.Client interface.
 func (w *Web) Credentials() *RequestCredentials {
@@ -55,227 +108,314 @@ func (w *Web) Credentials() *RequestCredentials {
 // InitializeCredentials starts an oauth login flow to retrieve an authorization code, then exchange
 // that authorization code for an access token and (if offline access is enabled) a refresh token.
 func (w *Web) InitializeCredentials() error {
-	err := w.setAuthorizationCode()
+	return w.InitializeCredentialsContext(context.Background())
+}
+
+// InitializeCredentialsContext is InitializeCredentials, but gives up waiting on the login flow and
+// cancels the token exchange when the given context is done.
+func (w *Web) InitializeCredentialsContext(ctx context.Context) error {
+	return w.RequestCredentials.refresh(ctx, w.Config.TokenExpirySkew(), w.signIn)
+}
+
+// signIn gets a token from the token cache, refreshing it if needed, and falls back to a browser
+// sign-in. A cached refresh token which is no longer accepted isn't an error, as the sign-in will
+// replace it.
+func (w *Web) signIn(ctx context.Context) (string, time.Time, error) {
+	if w.TokenCache != nil {
+		cached, err := w.TokenCache.Load(w.tokenCacheKey())
+		if err != nil {
+			return "", time.Time{}, fmt.Errorf("client.Web: loading token cache: %v", err)
+		}
+		if cached != nil && cached.RefreshToken != "" {
+			w.RefreshToken = cached.RefreshToken
+		}
+		if cached != nil && cached.AccessToken != "" && cached.AccessTokenExpiresAt.After(time.Now().Add(w.Config.TokenExpirySkew())) {
+			return cached.AccessToken, cached.AccessTokenExpiresAt, nil
+		}
+		if w.RefreshToken != "" && w.Scopes.HasScope(scopes.DelegatedOfflineAccess) {
+			accessToken, expiresAt, err := w.redeemRefreshToken(ctx)
+			var tokenErr *TokenError
+			if err == nil || !errors.As(err, &tokenErr) {
+				return accessToken, expiresAt, err
+			}
+		}
+	}
+	err := w.setAuthorizationCode(ctx)
 	if err != nil {
-		return err
+		return "", time.Time{}, err
 	}
-	err = w.setAccessToken()
-	return err
+	return w.redeemAuthorizationCode(ctx)
 }
 
-func (w *Web) localServer() *http.Server {
-	srv := &http.Server{Addr: fmt.Sprintf(":%v", w.LocalhostPort)}
-	http.HandleFunc("/login", func(wr http.ResponseWriter, r *http.Request) {
+// saveToken saves a token received by this client to its token cache, if it has one.
+func (w *Web) saveToken(accessToken string, expiresAt time.Time) error {
+	if w.TokenCache == nil {
+		return nil
+	}
+	err := w.TokenCache.Save(w.tokenCacheKey(), &CachedToken{
+		AccessToken:          accessToken,
+		AccessTokenExpiresAt: expiresAt,
+		RefreshToken:         w.RefreshToken,
+	})
+	if err != nil {
+		return fmt.Errorf("client.Web: saving token cache: %v", err)
+	}
+	return nil
+}
+
+// tokenCacheKey returns the key the tokens of this client are cached under.
+func (w *Web) tokenCacheKey() TokenCacheKey {
+	return newTokenCacheKey(w.ApplicationID, delegatedTenant(w.TenantID), w.Account, w.Scopes)
+}
+
+// loginResult is what the /login redirect handler hands back to the waiting sign-in.
+type loginResult struct {
+	code string
+	err  error
+}
+
+// localServer starts listening for the login redirect on the loopback interface, as anyone able to
+// reach the handler could hand it an authorization code. It listens on LocalhostPort, or an
+// ephemeral port when that is zero, and records the port in redirectPort. The handler lives on a
+// private ServeMux, so several sign-ins can run over the lifetime of the process.
+func (w *Web) localServer(results chan<- loginResult) (*http.Server, error) {
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%v", w.LocalhostPort))
+	if err != nil {
+		return nil, fmt.Errorf("client.Web: could not listen for the login redirect: %v", err)
+	}
+	w.redirectPort = listener.Addr().(*net.TCPAddr).Port
+	state := w.state
+	deliver := func(result loginResult) {
+		// Only the first result counts; later redirects are answered but otherwise ignored.
+		select {
+		case results <- result:
+		default:
+		}
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(wr http.ResponseWriter, r *http.Request) {
 		err := r.ParseForm()
 		if err != nil {
-			w.Error = fmt.Errorf("Error while parsing form from response %s", err)
+			wr.WriteHeader(http.StatusBadRequest)
+			deliver(loginResult{err: fmt.Errorf("Error while parsing form from response %s", err)})
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(r.Form.Get("state")), []byte(state)) != 1 {
+			// A mismatched state means this redirect wasn't started by us; ignore it rather than
+			// failing the sign-in in progress.
+			wr.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(wr, "invalid state parameter")
 			return
 		}
 		if v, ok := r.Form["error"]; ok && len(v) > 0 {
 			errorDescription, ok := r.Form["error_description"]
 			if ok && len(errorDescription) > 0 {
 				err = fmt.Errorf("%v: %v", strings.Join(v, ""), errorDescription)
-				fmt.Fprintf(wr, "%v", err)
-				w.Error = err
 			} else {
 				err = fmt.Errorf("%v", strings.Join(v, ""))
-				fmt.Fprintf(wr, "%v", err)
-				w.Error = err
 			}
+			fmt.Fprintf(wr, "%v", err)
+			deliver(loginResult{err: err})
 			return
 		}
 		code, codeOk := r.Form["code"]
 		if len(code) > 0 && codeOk {
 			fmt.Fprintf(wr, "authorization done. you may close this window now")
-			w.AuthorizationCode = strings.Join(code, "")
+			deliver(loginResult{code: strings.Join(code, "")})
 			return
 		}
 		err = fmt.Errorf("error getting authorization code from login response")
 		fmt.Fprintf(wr, "%v", err)
-		w.Error = err
+		deliver(loginResult{err: err})
 	})
+	srv := &http.Server{Handler: mux}
 	go func() {
-		if err := srv.ListenAndServe(); err != nil {
-			// This will throw an error when we shutdown the server during the normal authorization flow
-			// So we try to catch that error, and only return the real error if it isn't the expected
-			// error.
-			if !strings.Contains(err.Error(), "Server closed") {
-				w.Error = fmt.Errorf("error on ListenAndServe: %v", err)
-			}
+		if err := srv.Serve(listener); err != nil && err != http.ErrServerClosed {
+			deliver(loginResult{err: fmt.Errorf("error on Serve: %v", err)})
 		}
 	}()
-	return srv
+	return srv, nil
 }
 
+// redirectURI returns the redirect uri of the last sign-in, or the configured one before the first.
 func (w *Web) redirectURI() string {
-	return fmt.Sprintf("http://localhost:%v/login", w.LocalhostPort)
+	port := w.redirectPort
+	if port == 0 {
+		port = w.LocalhostPort
+	}
+	return fmt.Sprintf("http://127.0.0.1:%v/login", port)
 }
 
 // RefreshCredentials will attempt to refresh the access token if it is expired. This call will fail
 // if the original authorization was not made with a Offline scope provided.
 func (w *Web) RefreshCredentials() error {
+	return w.RefreshCredentialsContext(context.Background())
+}
+
+// RefreshCredentialsContext is RefreshCredentials, with the token request bound to the given
+// context.
+func (w *Web) RefreshCredentialsContext(ctx context.Context) error {
+	return w.RequestCredentials.refresh(ctx, w.Config.TokenExpirySkew(), w.redeemRefreshToken)
+}
+
+// redeemRefreshToken exchanges the refresh token for a new access token and refresh token.
+func (w *Web) redeemRefreshToken(ctx context.Context) (string, time.Time, error) {
 	if !w.Scopes.HasScope(scopes.DelegatedOfflineAccess) {
-		return fmt.Errorf("this web client was not configured for offline access and token refresh. to configure this, provide an offline scope during the initial client authorization")
+		return "", time.Time{}, fmt.Errorf("this web client was not configured for offline access and token refresh. to configure this, provide an offline scope during the initial client authorization")
 	}
 	if w.RefreshToken == "" {
-		return fmt.Errorf("client.Web: no refresh token found in web client. call client.InitializeCredentials to fill this")
+		return "", time.Time{}, fmt.Errorf("client.Web: no refresh token found in web client. call client.InitializeCredentials to fill this")
 	}
-	w.RequestCredentials.AccessTokenUpdating.Lock()
-	defer w.RequestCredentials.AccessTokenUpdating.Unlock()
-	if w.RequestCredentials.AccessToken != "" && w.RequestCredentials.AccessTokenExpiresAt.After(time.Now()) {
-		return nil
-	}
-	tokenURI, err := url.Parse("https://login.microsoftonline.com/common/oauth2/v2.0/token")
-	if err != nil {
-		return err
-	}
-	resp, err := http.PostForm(tokenURI.String(), url.Values{
+	token, err := requestToken(ctx, w.Config, tokenURL(w.Config, delegatedTenant(w.TenantID)), url.Values{
 		"client_id":     {w.ApplicationID},
 		"grant_type":    {"refresh_token"},
 		"redirect_uri":  {w.redirectURI()},
 		"refresh_token": {w.RefreshToken},
 		"scope":         {w.Scopes.QueryString()},
-	})
+	}, true)
 	if err != nil {
-		return err
+		return "", time.Time{}, err
 	}
-	b, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return err
+	w.RefreshToken = token.RefreshToken
+	if err := w.setIDToken(ctx, token.IDToken, ""); err != nil {
+		return "", time.Time{}, err
 	}
-	var data map[string]interface{}
-	err = json.Unmarshal(b, &data)
-	if err != nil {
-		return err
+	return token.AccessToken, token.ExpiresAt, w.saveToken(token.AccessToken, token.ExpiresAt)
+}
+
+// redeemAuthorizationCode exchanges the authorization code of the last sign-in for an access token
+// and, with offline access, a refresh token.
+func (w *Web) redeemAuthorizationCode(ctx context.Context) (string, time.Time, error) {
+	if w.AuthorizationCode == "" {
+		return "", time.Time{}, fmt.Errorf("client.Web: no access code found in web client")
 	}
-	serverErrCode, ok := data["error"].(string)
-	if ok {
-		serverErr, ok := data["error_description"].(string)
-		if ok {
-			return fmt.Errorf("%v: %v", serverErrCode, serverErr)
-		}
-		return fmt.Errorf(serverErrCode)
+	form := url.Values{
+		"client_id":     {w.ApplicationID},
+		"code":          {w.AuthorizationCode},
+		"code_verifier": {w.codeVerifier},
+		"grant_type":    {"authorization_code"},
+		"redirect_uri":  {w.redirectURI()},
+		"scope":         {w.Scopes.QueryString()},
 	}
-	accessToken, ok := data["access_token"].(string)
-	if !ok || accessToken == "" {
-		return fmt.Errorf("no access token found in response")
+	if w.ApplicationSecret != "" {
+		form.Set("client_secret", w.ApplicationSecret)
 	}
-	durationSecs, ok := data["expires_in"].(float64)
-	if !ok || durationSecs == 0 {
-		return fmt.Errorf("no token duration found in response")
+	token, err := requestToken(ctx, w.Config, tokenURL(w.Config, delegatedTenant(w.TenantID)), form, w.Scopes.HasScope(scopes.DelegatedOfflineAccess))
+	if err != nil {
+		return "", time.Time{}, err
 	}
-	refreshToken, ok := data["refresh_token"].(string)
-	if !ok || refreshToken == "" {
-		return fmt.Errorf("no refresh token found in response")
+	if token.RefreshToken != "" {
+		w.RefreshToken = token.RefreshToken
 	}
-	expiresAt := time.Now().Add(time.Duration(durationSecs) * time.Second)
-	w.RequestCredentials.AccessToken = accessToken
-	w.RequestCredentials.AccessTokenExpiresAt = expiresAt
-	w.RefreshToken = refreshToken
-	return nil
+	if err := w.setIDToken(ctx, token.IDToken, w.nonce); err != nil {
+		return "", time.Time{}, err
+	}
+	return token.AccessToken, token.ExpiresAt, w.saveToken(token.AccessToken, token.ExpiresAt)
 }
 
-func (w *Web) setAccessToken() error {
-	if w.AuthorizationCode == "" {
-		return fmt.Errorf("client.Web: no access code found in web client")
-	}
-	w.RequestCredentials.AccessTokenUpdating.Lock()
-	defer w.RequestCredentials.AccessTokenUpdating.Unlock()
-	if w.RequestCredentials.AccessToken != "" && w.RequestCredentials.AccessTokenExpiresAt.After(time.Now()) {
+// setIDToken validates the ID token of a token response, if it has one, and records its claims.
+func (w *Web) setIDToken(ctx context.Context, idToken string, nonce string) error {
+	if idToken == "" {
 		return nil
 	}
-	tokenURI, err := url.Parse("https://login.microsoftonline.com/common/oauth2/v2.0/token")
+	claims, err := validateIDToken(ctx, w.Config, &w.keys, delegatedTenant(w.TenantID), w.ApplicationID, nonce, idToken)
 	if err != nil {
-		return err
+		return fmt.Errorf("client.Web: %v", err)
 	}
-	resp, err := http.PostForm(tokenURI.String(), url.Values{
-		"client_id":     {w.ApplicationID},
-		"client_secret": {w.ApplicationSecret},
-		"code":          {w.AuthorizationCode},
-		"grant_type":    {"authorization_code"},
-		"redirect_uri":  {w.redirectURI()},
-		"scope":         {w.Scopes.QueryString()},
-	})
+	w.IDToken = idToken
+	w.IDTokenClaims = claims
+	return nil
+}
+
+func (w *Web) setAuthorizationCode(ctx context.Context) error {
+	verifier, challenge, err := newPKCE()
 	if err != nil {
 		return err
 	}
-	b, err := ioutil.ReadAll(resp.Body)
+	state, err := randomToken(16)
 	if err != nil {
 		return err
 	}
-	var data map[string]interface{}
-	err = json.Unmarshal(b, &data)
+	nonce, err := randomToken(16)
 	if err != nil {
 		return err
 	}
-	serverErrCode, ok := data["error"].(string)
-	if ok {
-		serverErr, ok := data["error_description"].(string)
-		if ok {
-			return fmt.Errorf("%v: %v", serverErrCode, serverErr)
-		}
-		return fmt.Errorf(serverErrCode)
-	}
-	accessToken, ok := data["access_token"].(string)
-	if !ok || accessToken == "" {
-		return fmt.Errorf("no access token found in response")
-	}
-	durationSecs, ok := data["expires_in"].(float64)
-	if !ok || durationSecs == 0 {
-		return fmt.Errorf("no token duration found in response")
+	w.codeVerifier = verifier
+	w.nonce = nonce
+	w.state = state
+	uri, err := url.Parse(authorizeURL(w.Config, delegatedTenant(w.TenantID)))
+	if err != nil {
+		return err
 	}
-	if w.Scopes.HasScope(scopes.DelegatedOfflineAccess) {
-		refreshToken, ok := data["refresh_token"].(string)
-		if !ok || refreshToken == "" {
-			return fmt.Errorf("no refresh token found in response")
-		}
-		w.RefreshToken = refreshToken
+	// The server has to be listening before the redirect uri is built, as it may pick the port.
+	results := make(chan loginResult, 1)
+	server, err := w.localServer(results)
+	if err != nil {
+		return err
 	}
-	expiresAt := time.Now().Add(time.Duration(durationSecs) * time.Second)
-	w.RequestCredentials.AccessToken = accessToken
-	w.RequestCredentials.AccessTokenExpiresAt = expiresAt
-	return nil
-}
-
-func (w *Web) setAuthorizationCode() error {
 	formVals := url.Values{}
 	formVals.Set("client_id", w.ApplicationID)
+	formVals.Set("code_challenge", challenge)
+	formVals.Set("code_challenge_method", "S256")
 	formVals.Set("grant_type", "authorization_code")
+	if w.Account != "" {
+		formVals.Set("login_hint", w.Account)
+	}
+	formVals.Set("nonce", nonce)
 	formVals.Set("redirect_uri", w.redirectURI())
 	formVals.Set("response_mode", "query")
 	formVals.Set("response_type", "code")
 	formVals.Set("scope", w.Scopes.QueryString())
-	uri, err := url.Parse("https://login.microsoftonline.com/common/oauth2/v2.0/authorize")
-	if err != nil {
-		return err
-	}
+	formVals.Set("state", state)
 	uri.RawQuery = formVals.Encode()
+	openBrowser := w.OpenBrowser
+	if openBrowser == nil {
+		openBrowser = OpenSystemBrowser
+	}
+	timeout := w.LoginTimeout
+	if timeout <= 0 {
+		timeout = DefaultLoginTimeout
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	var result loginResult
+	if err := openBrowser(uri.String()); err != nil {
+		result.err = fmt.Errorf("client.Web: could not open a browser for sign-in: %v", err)
+	} else {
+		select {
+		case result = <-results:
+		case <-timer.C:
+			result.err = fmt.Errorf("client.Web: timed out after %v waiting for sign-in", timeout)
+		case <-ctx.Done():
+			result.err = ctx.Err()
+		}
+	}
+	// Give the browser a moment to receive the response page, but don't wait on connections it
+	// opened speculatively and never used.
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		server.Close()
+	}
+	if result.err != nil {
+		w.Error = result.err
+		return result.err
+	}
+	w.AuthorizationCode = result.code
+	return nil
+}
+
+// OpenSystemBrowser opens the given url in the default browser of the operating system. It's the
+// default OpenBrowser function of client.Web.
+func OpenSystemBrowser(uri string) error {
 	switch runtime.GOOS {
 	case "darwin":
-		err = exec.Command("open", uri.String()).Start()
+		return exec.Command("open", uri).Start()
 	case "linux":
-		err = exec.Command("xdg-open", uri.String()).Start()
+		return exec.Command("xdg-open", uri).Start()
 	case "windows":
-		err = exec.Command("rundll32", "url.dll,FileProtocolHandler", uri.String()).Start()
+		return exec.Command("rundll32", "url.dll,FileProtocolHandler", uri).Start()
 	default:
-		err = fmt.Errorf("unsupported platform")
+		return fmt.Errorf("unsupported platform %v", runtime.GOOS)
 	}
-	if err != nil {
-		log.Fatal(err)
-	}
-	server := w.localServer()
-	running := true
-	for running {
-		fmt.Printf("%v", w.AuthorizationCode)
-		if w.Error != nil || w.AuthorizationCode != "" {
-			if err := server.Shutdown(context.TODO()); err != nil {
-				return fmt.Errorf("error on server shutdown: %v", err)
-			}
-			if w.Error != nil {
-				return w.Error
-			}
-			return nil
-		}
-	}
-	return nil
 }
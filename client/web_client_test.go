@@ -0,0 +1,131 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mhoc/msgoraph/scopes"
+)
+
+func TestWebClientInitialization(t *testing.T) {
+	var challenge string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Fatal(err)
+		}
+		if got := r.PostForm.Get("code"); got != "code" {
+			t.Errorf("unexpected code %v", got)
+		}
+		if _, ok := r.PostForm["client_secret"]; ok {
+			t.Error("public clients should not send a client_secret")
+		}
+		if got := pkceChallenge(r.PostForm.Get("code_verifier")); got != challenge {
+			t.Errorf("code_verifier does not match challenge %v", challenge)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "token",
+			"expires_in":   3600,
+		})
+	}))
+	defer srv.Close()
+	c := NewWeb("app", "", 0, scopes.Scopes{scopes.DelegatedUserRead})
+	c.Config = &Config{AuthorityHost: srv.URL, HTTPClient: srv.Client()}
+	c.OpenBrowser = func(uri string) error {
+		u, err := url.Parse(uri)
+		if err != nil {
+			return err
+		}
+		q := u.Query()
+		challenge = q.Get("code_challenge")
+		go func() {
+			// A redirect carrying someone else's state must not complete the sign-in.
+			resp, err := http.Get(q.Get("redirect_uri") + "?code=forged&state=forged")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Errorf("forged redirect got status %v", resp.StatusCode)
+			}
+			resp, err = http.Get(q.Get("redirect_uri") + "?code=code&state=" + url.QueryEscape(q.Get("state")))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.Body.Close()
+		}()
+		return nil
+	}
+	// Signing in twice checks the login handler is not registered globally.
+	for i := 0; i < 2; i++ {
+		c.RequestCredentials = &RequestCredentials{}
+		if err := c.InitializeCredentials(); err != nil {
+			t.Fatal(err)
+		}
+		if c.Credentials().AccessToken != "token" {
+			t.Fatalf("expected access token to be set, got %q", c.Credentials().AccessToken)
+		}
+		if c.LocalhostPort != 0 {
+			t.Fatalf("expected the ephemeral port not to be pinned, got %v", c.LocalhostPort)
+		}
+	}
+}
+
+func TestWebClientListensOnLoopback(t *testing.T) {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var external []net.IP
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLoopback() && ipNet.IP.To4() != nil {
+			external = append(external, ipNet.IP)
+		}
+	}
+	if len(external) == 0 {
+		t.Skip("no non-loopback interface to check against")
+	}
+	c := NewWeb("app", "", 0, scopes.Scopes{scopes.DelegatedUserRead})
+	c.OpenBrowser = func(uri string) error {
+		u, err := url.Parse(uri)
+		if err != nil {
+			return err
+		}
+		redirect, err := url.Parse(u.Query().Get("redirect_uri"))
+		if err != nil {
+			return err
+		}
+		if redirect.Hostname() != "127.0.0.1" {
+			t.Errorf("expected the redirect uri to name the loopback address, got %v", redirect)
+		}
+		for _, ip := range external {
+			conn, err := net.DialTimeout("tcp", net.JoinHostPort(ip.String(), redirect.Port()), time.Second)
+			if err == nil {
+				conn.Close()
+				t.Errorf("login redirect handler is reachable on %v", ip)
+			}
+		}
+		return errors.New("done")
+	}
+	if err := c.InitializeCredentials(); err == nil || !strings.Contains(err.Error(), "done") {
+		t.Fatalf("expected the sign-in to stop at the browser, got %v", err)
+	}
+}
+
+func TestWebClientLoginTimeout(t *testing.T) {
+	c := NewWeb("app", "", 0, scopes.Scopes{scopes.DelegatedUserRead})
+	c.LoginTimeout = 10 * time.Millisecond
+	c.OpenBrowser = func(string) error { return nil }
+	err := c.InitializeCredentials()
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+}
@@ -0,0 +1,45 @@
+package common
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// dateTimeLayout is the layout of the DateTime of a DateTimeTimeZone, with the seven digits of
+	// fractional seconds Graph writes.
+	dateTimeLayout = "2006-01-02T15:04:05.0000000"
+	// dateTimeParseLayout accepts any number of fractional digits, as Graph sometimes returns fewer.
+	dateTimeParseLayout = "2006-01-02T15:04:05.9999999"
+)
+
+// DateTimeTimeZone describes a date, time and time zone of a point in time, as used by calendar
+// events and mailbox settings. DateTime has no offset, it's interpreted in TimeZone, which is
+// either a Windows time zone name such as "Pacific Standard Time" or an IANA name such as
+// "America/Los_Angeles".
+type DateTimeTimeZone struct {
+	DateTime string `json:"dateTime"`
+	TimeZone string `json:"timeZone"`
+}
+
+// NewDateTimeTimeZone returns the given time as a DateTimeTimeZone in UTC.
+func NewDateTimeTimeZone(t time.Time) DateTimeTimeZone {
+	return DateTimeTimeZone{
+		DateTime: t.UTC().Format(dateTimeLayout),
+		TimeZone: "UTC",
+	}
+}
+
+// Time parses the point in time. It fails for Windows time zone names other than UTC, which Go
+// doesn't know about.
+func (d DateTimeTimeZone) Time() (time.Time, error) {
+	loc := time.UTC
+	if d.TimeZone != "" && d.TimeZone != "UTC" {
+		var err error
+		loc, err = time.LoadLocation(d.TimeZone)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("unknown time zone %q: %v", d.TimeZone, err)
+		}
+	}
+	return time.ParseInLocation(dateTimeParseLayout, d.DateTime, loc)
+}
@@ -0,0 +1,53 @@
+package common
+
+import (
+	"encoding/json"
+)
+
+// The @odata.type values of the directory objects returned by navigation properties such as a
+// user's memberOf or ownedObjects.
+const (
+	ODataTypeAdministrativeUnit = "#microsoft.graph.administrativeUnit"
+	ODataTypeApplication        = "#microsoft.graph.application"
+	ODataTypeDevice             = "#microsoft.graph.device"
+	ODataTypeDirectoryRole      = "#microsoft.graph.directoryRole"
+	ODataTypeGroup              = "#microsoft.graph.group"
+	ODataTypeOrgContact         = "#microsoft.graph.orgContact"
+	ODataTypeServicePrincipal   = "#microsoft.graph.servicePrincipal"
+	ODataTypeUser               = "#microsoft.graph.user"
+)
+
+// DirectoryObject is any object in the directory, such as a user, group or device. Navigation
+// properties which can return several kinds of object, like a user's memberOf, return these;
+// ODataType tells which kind each one is, and Decode unmarshals it into the full type.
+type DirectoryObject struct {
+	DisplayName string `json:"displayName"`
+	ID          string `json:"id"`
+	ODataType   string `json:"@odata.type"`
+
+	// Raw is the json the object was decoded from.
+	Raw json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON decodes the common properties of the object, keeping the json in Raw.
+func (o *DirectoryObject) UnmarshalJSON(b []byte) error {
+	type plain DirectoryObject
+	var p plain
+	if err := json.Unmarshal(b, &p); err != nil {
+		return err
+	}
+	*o = DirectoryObject(p)
+	o.Raw = append(json.RawMessage(nil), b...)
+	return nil
+}
+
+// Decode unmarshals the object into v, which should be the type matching ODataType, such as a
+// users.User for ODataTypeUser.
+func (o DirectoryObject) Decode(v interface{}) error {
+	return json.Unmarshal(o.Raw, v)
+}
+
+// IsType reports whether the object is of the given @odata.type, such as ODataTypeGroup.
+func (o DirectoryObject) IsType(odataType string) bool {
+	return o.ODataType == odataType
+}
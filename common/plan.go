@@ -16,3 +16,13 @@ type ProvisionedPlan struct {
 	ProvisioningStatus string `json:"provisioningStatus"`
 	Service            string `json:"service"`
 }
+
+// ServicePlanInfo describes one of the service plans included in a license, such as Exchange Online
+// in an Office 365 license. Its ServicePlanID is what goes in the disabled plans of an assigned
+// license.
+type ServicePlanInfo struct {
+	AppliesTo          string `json:"appliesTo"`
+	ProvisioningStatus string `json:"provisioningStatus"`
+	ServicePlanID      string `json:"servicePlanId"`
+	ServicePlanName    string `json:"servicePlanName"`
+}
@@ -1 +1,3 @@
 module github.com/mhoc/msgoraph
+
+go 1.24
@@ -0,0 +1,179 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/mhoc/msgoraph/client"
+)
+
+const (
+	// MaxBatchSize is the most requests the Graph API accepts in a single JSON batch.
+	MaxBatchSize = 20
+)
+
+// BatchRequest is a single request inside of a JSON batch. The URL is relative to the version
+// specifier, such as "/users/{id}". See https://docs.microsoft.com/en-us/graph/json-batching.
+type BatchRequest struct {
+	ID        string            `json:"id"`
+	Method    string            `json:"method"`
+	URL       string            `json:"url"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	Body      interface{}       `json:"body,omitempty"`
+	DependsOn []string          `json:"dependsOn,omitempty"`
+}
+
+// BatchResponse is the response to a single request inside of a JSON batch.
+type BatchResponse struct {
+	ID      string            `json:"id"`
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers"`
+	Body    json.RawMessage   `json:"body"`
+}
+
+// Err returns a *client.GraphError if the response has a non-2xx status code, and nil otherwise.
+func (r BatchResponse) Err() error {
+	if isSuccess(r.Status) {
+		return nil
+	}
+	header := http.Header{}
+	for k, v := range r.Headers {
+		header.Set(k, v)
+	}
+	return newGraphError(r.Status, header, r.Body)
+}
+
+type batchRequestBody struct {
+	Requests []BatchRequest `json:"requests"`
+}
+
+type batchResponseBody struct {
+	Responses []BatchResponse `json:"responses"`
+}
+
+// Batch sends the given requests to the $batch endpoint of the given api version, such as "v1.0",
+// and returns every response keyed by request id. Requests are split across as many batches as
+// needed to stay within MaxBatchSize, keeping every request in the same batch as the requests it
+// depends on. The returned error only reflects failures of the batches themselves; the failure of
+// an individual request is reported by the Err method on its response. Batches are sent one after
+// another and the first failure stops the rest, but the responses of the batches sent before it
+// are still returned along with the error, as those requests have already taken effect.
+//
+// Requests throttled inside a batch are not retried, even with a client.RetryTransport, since the
+// batch itself succeeded. Their responses satisfy client.IsThrottled, and the GraphError from Err
+// carries the Retry-After of the request, so callers can queue them again after the delay.
+func Batch(ctx context.Context, client client.Client, version string, requests []BatchRequest) (map[string]BatchResponse, error) {
+	chunks, err := splitBatch(requests)
+	if err != nil {
+		return nil, err
+	}
+	responses := make(map[string]BatchResponse, len(requests))
+	for _, chunk := range chunks {
+		for i := range chunk {
+			if chunk[i].Body != nil && !hasHeader(chunk[i].Headers, "Content-Type") {
+				headers := map[string]string{"Content-Type": "application/json"}
+				for k, v := range chunk[i].Headers {
+					headers[k] = v
+				}
+				chunk[i].Headers = headers
+			}
+		}
+		b, err := GraphRequestContext(ctx, client, "POST", version+"/$batch", nil, batchRequestBody{Requests: chunk})
+		if err != nil {
+			return responses, err
+		}
+		var data batchResponseBody
+		err = json.Unmarshal(b, &data)
+		if err != nil {
+			return responses, err
+		}
+		for _, resp := range data.Responses {
+			responses[resp.ID] = resp
+		}
+	}
+	for _, req := range requests {
+		if _, ok := responses[req.ID]; !ok {
+			return responses, fmt.Errorf("no response for batch request %v", req.ID)
+		}
+	}
+	return responses, nil
+}
+
+// hasHeader reports whether the headers of a batch request set the given one, whose name is
+// matched case-insensitively as http headers are.
+func hasHeader(headers map[string]string, name string) bool {
+	for k, v := range headers {
+		if strings.EqualFold(k, name) && v != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// splitBatch partitions requests into batches of at most MaxBatchSize, keeping the original order
+// and never separating a request from the requests it depends on, directly or transitively.
+func splitBatch(requests []BatchRequest) ([][]BatchRequest, error) {
+	index := make(map[string]int, len(requests))
+	for i, req := range requests {
+		if req.ID == "" {
+			return nil, fmt.Errorf("batch request %v has no id", i)
+		}
+		if _, ok := index[req.ID]; ok {
+			return nil, fmt.Errorf("duplicate batch request id %v", req.ID)
+		}
+		index[req.ID] = i
+	}
+	// Union each request with its dependencies, so every connected group of requests ends up with
+	// a single root.
+	parent := make([]int, len(requests))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for i, req := range requests {
+		for _, dep := range req.DependsOn {
+			j, ok := index[dep]
+			if !ok {
+				return nil, fmt.Errorf("batch request %v depends on unknown request %v", req.ID, dep)
+			}
+			parent[find(i)] = find(j)
+		}
+	}
+	var groups [][]BatchRequest
+	groupOf := map[int]int{}
+	for i, req := range requests {
+		root := find(i)
+		g, ok := groupOf[root]
+		if !ok {
+			g = len(groups)
+			groupOf[root] = g
+			groups = append(groups, nil)
+		}
+		groups[g] = append(groups[g], req)
+	}
+	var chunks [][]BatchRequest
+	var current []BatchRequest
+	for _, group := range groups {
+		if len(group) > MaxBatchSize {
+			return nil, fmt.Errorf("batch request %v is part of a dependency chain of %v requests, more than the %v allowed in one batch", group[0].ID, len(group), MaxBatchSize)
+		}
+		if len(current)+len(group) > MaxBatchSize {
+			chunks = append(chunks, current)
+			current = nil
+		}
+		current = append(current, group...)
+	}
+	if len(current) > 0 {
+		chunks = append(chunks, current)
+	}
+	return chunks, nil
+}
@@ -0,0 +1,139 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mhoc/msgoraph/client"
+)
+
+func TestSplitBatch(t *testing.T) {
+	var requests []BatchRequest
+	for i := 1; i <= 45; i++ {
+		requests = append(requests, BatchRequest{ID: strconv.Itoa(i), Method: "GET", URL: "/users"})
+	}
+	// Chain 19 through 22 together, which would otherwise straddle the first two batches.
+	for i := 19; i < 22; i++ {
+		requests[i].DependsOn = []string{strconv.Itoa(i)}
+	}
+	chunks, err := splitBatch(requests)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) != 3 {
+		t.Fatalf("expected 3 batches, got %v", len(chunks))
+	}
+	if len(chunks[0]) != 18 || chunks[1][0].ID != "19" || chunks[1][3].ID != "22" {
+		t.Fatalf("expected the dependency chain to move to the second batch, got %v and %v requests", len(chunks[0]), len(chunks[1]))
+	}
+	total := 0
+	for _, chunk := range chunks {
+		if len(chunk) > MaxBatchSize {
+			t.Fatalf("batch of %v requests is over the limit", len(chunk))
+		}
+		total += len(chunk)
+	}
+	if total != len(requests) {
+		t.Fatalf("expected %v requests across all batches, got %v", len(requests), total)
+	}
+}
+
+func TestSplitBatchUnknownDependency(t *testing.T) {
+	_, err := splitBatch([]BatchRequest{{ID: "1", DependsOn: []string{"2"}}})
+	if err == nil {
+		t.Fatalf("expected an error for a dependency on an unknown request")
+	}
+}
+
+func TestBatchReturnsCompletedResponses(t *testing.T) {
+	posts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		posts++
+		if posts > 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":{"code":"BadRequest","message":"boom"}}`))
+			return
+		}
+		var body batchRequestBody
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		var data batchResponseBody
+		for _, req := range body.Requests {
+			resp := BatchResponse{ID: req.ID, Status: http.StatusNoContent}
+			if req.ID == "2" {
+				resp = BatchResponse{ID: req.ID, Status: http.StatusTooManyRequests, Headers: map[string]string{"Retry-After": "7"}}
+			}
+			data.Responses = append(data.Responses, resp)
+		}
+		json.NewEncoder(w).Encode(data)
+	}))
+	defer srv.Close()
+	var requests []BatchRequest
+	for i := 1; i <= MaxBatchSize+1; i++ {
+		requests = append(requests, BatchRequest{ID: strconv.Itoa(i), Method: "DELETE", URL: "/users/" + strconv.Itoa(i)})
+	}
+	responses, err := Batch(context.Background(), newTestClient(srv), "v1.0", requests)
+	if err == nil {
+		t.Fatal("expected the failure of the second batch to be returned")
+	}
+	if len(responses) != MaxBatchSize {
+		t.Fatalf("expected the %v responses of the first batch, got %v", MaxBatchSize, len(responses))
+	}
+	if _, ok := responses[strconv.Itoa(MaxBatchSize+1)]; ok {
+		t.Fatal("unexpected response for a request of the failed batch")
+	}
+	throttled := responses["2"].Err()
+	if !client.IsThrottled(throttled) {
+		t.Fatalf("expected a throttled error, got %v", throttled)
+	}
+	var gErr *client.GraphError
+	if !errors.As(throttled, &gErr) {
+		t.Fatalf("expected a GraphError, got %T", throttled)
+	}
+	if delay, ok := gErr.RetryAfter(); !ok || delay != 7*time.Second {
+		t.Fatalf("unexpected retry after %v", delay)
+	}
+}
+
+func TestBatchContentTypeDefault(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body batchRequestBody
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		var data batchResponseBody
+		for _, req := range body.Requests {
+			var contentTypes []string
+			for k, v := range req.Headers {
+				if strings.EqualFold(k, "Content-Type") {
+					contentTypes = append(contentTypes, v)
+				}
+			}
+			expected := "application/json"
+			if req.ID == "2" {
+				expected = "text/plain"
+			}
+			if len(contentTypes) != 1 || contentTypes[0] != expected {
+				t.Errorf("request %v: expected the content type %v, got %v", req.ID, expected, contentTypes)
+			}
+			data.Responses = append(data.Responses, BatchResponse{ID: req.ID, Status: http.StatusNoContent})
+		}
+		json.NewEncoder(w).Encode(data)
+	}))
+	defer srv.Close()
+	requests := []BatchRequest{
+		{ID: "1", Method: "PATCH", URL: "/users/1", Body: map[string]string{"jobTitle": "Engineer"}},
+		{ID: "2", Method: "PUT", URL: "/users/2/photo/$value", Body: "image", Headers: map[string]string{"content-type": "text/plain"}},
+	}
+	if _, err := Batch(context.Background(), newTestClient(srv), "v1.0", requests); err != nil {
+		t.Fatal(err)
+	}
+}
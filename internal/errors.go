@@ -0,0 +1,52 @@
+package internal
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/mhoc/msgoraph/client"
+)
+
+// graphErrorBody is the shape of the error payload the Graph API returns alongside non-2xx
+// responses.
+type graphErrorBody struct {
+	Error struct {
+		Code       string `json:"code"`
+		Message    string `json:"message"`
+		InnerError struct {
+			Date      string `json:"date"`
+			RequestID string `json:"request-id"`
+		} `json:"innerError"`
+	} `json:"error"`
+}
+
+// isSuccess returns true for 2xx status codes.
+func isSuccess(statusCode int) bool {
+	return statusCode >= 200 && statusCode < 300
+}
+
+// newGraphError builds a client.GraphError out of a non-2xx response. Bodies which aren't a Graph
+// error object still produce an error, with the body text as the message.
+func newGraphError(statusCode int, header http.Header, body []byte) *client.GraphError {
+	gErr := &client.GraphError{
+		StatusCode: statusCode,
+		Header:     header,
+	}
+	var data graphErrorBody
+	if err := json.Unmarshal(body, &data); err == nil && data.Error.Code != "" {
+		gErr.Code = data.Error.Code
+		gErr.Message = data.Error.Message
+		gErr.RequestID = data.Error.InnerError.RequestID
+		gErr.Date = data.Error.InnerError.Date
+	} else {
+		gErr.Message = strings.TrimSpace(string(body))
+	}
+	if gErr.RequestID == "" && header != nil {
+		gErr.RequestID = header.Get("request-id")
+	}
+	if gErr.Date == "" && header != nil {
+		gErr.Date = header.Get("Date")
+	}
+	return gErr
+}
@@ -2,52 +2,63 @@ package internal
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strings"
 
 	"github.com/mhoc/msgoraph/client"
 )
 
 const (
-	// GraphAPIRootURL is the root url that the Graph API is hosted on.
-	GraphAPIRootURL = "https://graph.microsoft.com/"
+	// GraphAPIRootURL is the root url that the Graph API is hosted on in the public cloud. Requests
+	// are sent to the root url in the client's Configuration, which defaults to this.
+	GraphAPIRootURL = client.DefaultGraphRootURL
 )
 
 // BasicGraphRequest is similar to GraphRequest, but it assumes an already fully formed url and no
 // body. This is primarily useful for methods that need to pagniate; it just makes that a little bit
 // easier.
 func BasicGraphRequest(client client.Client, method string, url string) ([]byte, error) {
-	req, err := http.NewRequest(method, url, nil)
-	if err != nil {
-		return nil, err
-	}
-	err = client.RefreshCredentials()
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Add("Authorization", fmt.Sprintf("Bearer %v", client.Credentials().AccessToken))
-	req.Header.Add("Content-Type", "application/json")
-	resp, err := http.DefaultClient.Do(req)
+	return BasicGraphRequestContext(context.Background(), client, method, url)
+}
+
+// BasicGraphRequestContext is BasicGraphRequest, with the request and any credential refresh it
+// triggers bound to the given context.
+func BasicGraphRequestContext(ctx context.Context, client client.Client, method string, url string) ([]byte, error) {
+	return BasicGraphRequestWithHeader(ctx, client, method, url, nil)
+}
+
+// BasicGraphRequestWithHeader is BasicGraphRequestContext, with the given headers added to the
+// request.
+func BasicGraphRequestWithHeader(ctx context.Context, client client.Client, method string, url string, header http.Header) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
 	if err != nil {
 		return nil, err
 	}
-	return ioutil.ReadAll(resp.Body)
+	addHeader(req, header)
+	return doGraphRequest(ctx, client, req)
 }
 
 // GraphRequest creates and executes a new http request against the Graph API. The path
 // provided should be the entire path of the url, including the version specifier. It returns the
 // response body, along with any errors that might occur during the request process.
 func GraphRequest(client client.Client, method string, path string, params url.Values, body interface{}) ([]byte, error) {
-	var graphURL string
-	if len(params) > 0 {
-		graphURL = fmt.Sprintf("%v%v?%v", GraphAPIRootURL, path, params.Encode())
-	} else {
-		graphURL = fmt.Sprintf("%v%v", GraphAPIRootURL, path)
-	}
+	return GraphRequestContext(context.Background(), client, method, path, params, body)
+}
+
+// GraphRequestContext is GraphRequest, with the request and any credential refresh it triggers
+// bound to the given context.
+func GraphRequestContext(ctx context.Context, client client.Client, method string, path string, params url.Values, body interface{}) ([]byte, error) {
+	return GraphRequestWithHeader(ctx, client, method, path, params, nil, body)
+}
+
+// GraphRequestWithHeader is GraphRequestContext, with the given headers added to the request.
+func GraphRequestWithHeader(ctx context.Context, client client.Client, method string, path string, params url.Values, header http.Header, body interface{}) ([]byte, error) {
 	var bodyBuffered io.Reader
 	if body != nil {
 		j, err := json.Marshal(body)
@@ -56,19 +67,134 @@ func GraphRequest(client client.Client, method string, path string, params url.V
 		}
 		bodyBuffered = bytes.NewBuffer(j)
 	}
-	req, err := http.NewRequest(method, graphURL, bodyBuffered)
+	req, err := http.NewRequestWithContext(ctx, method, GraphURL(client, path, params), bodyBuffered)
 	if err != nil {
 		return nil, err
 	}
-	err = client.RefreshCredentials()
+	addHeader(req, header)
+	return doGraphRequest(ctx, client, req)
+}
+
+// GraphStreamRequest sends a request without a body against the Graph API and returns the
+// response with its body unread, for binary content such as photos. The caller has to close the
+// body. Responses with a non-2xx status code are returned as a *client.GraphError.
+func GraphStreamRequest(ctx context.Context, client client.Client, method string, path string, params url.Values) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, GraphURL(client, path, params), nil)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Add("Authorization", fmt.Sprintf("Bearer %v", client.Credentials().AccessToken))
-	req.Header.Add("Content-Type", "application/json")
-	resp, err := http.DefaultClient.Do(req)
+	return sendGraphRequest(ctx, client, req)
+}
+
+// GraphRawRequest is GraphRequestContext, sending the body as is with the given content type
+// instead of encoding it as json.
+func GraphRawRequest(ctx context.Context, client client.Client, method string, path string, params url.Values, contentType string, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, GraphURL(client, path, params), bytes.NewReader(body))
 	if err != nil {
 		return nil, err
 	}
+	req.Header.Set("Content-Type", contentType)
+	return doGraphRequest(ctx, client, req)
+}
+
+// GraphURL returns the fully formed url for the given path and query parameters, rooted at the
+// Graph API root url configured on the client.
+func GraphURL(client client.Client, path string, params url.Values) string {
+	root := clientConfig(client).GraphRoot()
+	if len(params) > 0 {
+		return fmt.Sprintf("%v%v?%v", root, path, params.Encode())
+	}
+	return fmt.Sprintf("%v%v", root, path)
+}
+
+// clientConfig returns the Config of a client which is client.Configurable, or nil for the
+// defaults.
+func clientConfig(c client.Client) *client.Config {
+	if cc, ok := c.(client.Configurable); ok {
+		return cc.Configuration()
+	}
+	return nil
+}
+
+// clientRefresh refreshes the credentials of a client, bound to the given context if it's a
+// client.ContextClient.
+func clientRefresh(ctx context.Context, c client.Client) error {
+	if cc, ok := c.(client.ContextClient); ok {
+		return cc.RefreshCredentialsContext(ctx)
+	}
+	return c.RefreshCredentials()
+}
+
+func addHeader(req *http.Request, header http.Header) {
+	for k, vs := range header {
+		for _, v := range vs {
+			req.Header.Add(k, v)
+		}
+	}
+}
+
+// doGraphRequest authenticates the given request with the client's credentials and sends it over
+// the client's configured http client, returning the response body. Responses with a non-2xx
+// status code are returned as a *client.GraphError.
+func doGraphRequest(ctx context.Context, client client.Client, req *http.Request) ([]byte, error) {
+	resp, err := sendGraphRequest(ctx, client, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
 	return ioutil.ReadAll(resp.Body)
 }
+
+// sendGraphRequest is doGraphRequest, returning the successful response with its body unread. The
+// request is sent as json unless it already has a Content-Type. If the Graph API rejects the access
+// token as invalid, which happens when it's revoked before its expiry, the token is refreshed and
+// the request retried once.
+func sendGraphRequest(ctx context.Context, client client.Client, req *http.Request) (*http.Response, error) {
+	for retried := false; ; retried = true {
+		err := clientRefresh(ctx, client)
+		if err != nil {
+			return nil, err
+		}
+		token, _ := client.Credentials().Token()
+		attempt := req.Clone(ctx)
+		if retried && req.GetBody != nil {
+			attempt.Body, err = req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+		}
+		attempt.Header.Add("Authorization", fmt.Sprintf("Bearer %v", token))
+		if attempt.Header.Get("Content-Type") == "" {
+			attempt.Header.Add("Content-Type", "application/json")
+		}
+		resp, err := clientConfig(client).HTTP().Do(attempt)
+		if err != nil {
+			return nil, err
+		}
+		if isSuccess(resp.StatusCode) {
+			return resp, nil
+		}
+		b, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		gErr := newGraphError(resp.StatusCode, resp.Header, b)
+		canReplay := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+		if retried || !canReplay || !isInvalidToken(gErr) {
+			return nil, gErr
+		}
+		// Invalidating only the rejected token means concurrent requests rejected with it trigger a
+		// single refresh between them.
+		client.Credentials().Invalidate(token)
+	}
+}
+
+// isInvalidToken reports whether the Graph API rejected the access token itself, rather than the
+// permissions it carries.
+func isInvalidToken(gErr *client.GraphError) bool {
+	if gErr.StatusCode != http.StatusUnauthorized {
+		return false
+	}
+	return gErr.Code == "InvalidAuthenticationToken" || strings.Contains(gErr.Header.Get("WWW-Authenticate"), "invalid_token")
+}
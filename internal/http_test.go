@@ -0,0 +1,142 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mhoc/msgoraph/client"
+)
+
+// testClient is a client.Client with a fixed access token, pointed at an httptest.Server.
+type testClient struct {
+	config      *client.Config
+	credentials *client.RequestCredentials
+}
+
+func newTestClient(srv *httptest.Server) *testClient {
+	return &testClient{
+		config: &client.Config{GraphRootURL: srv.URL, HTTPClient: srv.Client()},
+		credentials: &client.RequestCredentials{
+			AccessToken:          "token",
+			AccessTokenExpiresAt: time.Now().Add(time.Hour),
+		},
+	}
+}
+
+func (c *testClient) Configuration() *client.Config                          { return c.config }
+func (c *testClient) Credentials() *client.RequestCredentials                { return c.credentials }
+func (c *testClient) InitializeCredentials() error                           { return nil }
+func (c *testClient) InitializeCredentialsContext(ctx context.Context) error { return nil }
+func (c *testClient) RefreshCredentials() error                              { return nil }
+func (c *testClient) RefreshCredentialsContext(ctx context.Context) error    { return nil }
+
+func TestGraphRequestError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer token" {
+			t.Errorf("unexpected authorization header %q", got)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":{"code":"Request_ResourceNotFound","message":"Resource 'x' does not exist.","innerError":{"request-id":"abc","date":"2018-01-01T00:00:00"}}}`))
+	}))
+	defer srv.Close()
+	_, err := GraphRequest(newTestClient(srv), "GET", "v1.0/users/x", nil, nil)
+	if !client.IsNotFound(err) {
+		t.Fatalf("expected a not found error, got %v", err)
+	}
+	var gErr *client.GraphError
+	if !errors.As(err, &gErr) {
+		t.Fatalf("expected a *client.GraphError, got %T", err)
+	}
+	if gErr.Code != "Request_ResourceNotFound" || gErr.RequestID != "abc" || gErr.Date != "2018-01-01T00:00:00" {
+		t.Fatalf("unexpected error contents %+v", gErr)
+	}
+	if client.IsThrottled(err) || client.IsUnauthorized(err) {
+		t.Fatalf("404 should not match other sentinels")
+	}
+}
+
+func TestGraphRequestRetriesInvalidToken(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer second" {
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":{"code":"InvalidAuthenticationToken","message":"Access token has expired or is not yet valid."}}`))
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+	tokens := []string{"first", "second", "third"}
+	c := client.NewTokenSource(func(ctx context.Context) (string, time.Time, error) {
+		token := tokens[0]
+		tokens = tokens[1:]
+		return token, time.Now().Add(time.Hour), nil
+	})
+	c.Config = &client.Config{GraphRootURL: srv.URL, HTTPClient: srv.Client()}
+	if _, err := GraphRequest(c, "PATCH", "v1.0/users/x", nil, map[string]string{"a": "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) != 1 {
+		t.Fatalf("expected exactly one forced refresh, %v tokens left", len(tokens))
+	}
+}
+
+func TestGraphRawAndStreamRequests(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "PUT":
+			if got := r.Header.Get("Content-Type"); got != "image/png" {
+				t.Errorf("unexpected content type %q", got)
+			}
+			b, _ := ioutil.ReadAll(r.Body)
+			if string(b) != "png" {
+				t.Errorf("unexpected body %q", b)
+			}
+		case "GET":
+			w.Header().Set("Content-Type", "image/jpeg")
+			w.Write([]byte("jpeg"))
+		}
+	}))
+	defer srv.Close()
+	c := newTestClient(srv)
+	if _, err := GraphRawRequest(context.Background(), c, "PUT", "v1.0/users/x/photo/$value", nil, "image/png", []byte("png")); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := GraphStreamRequest(context.Background(), c, "GET", "v1.0/users/x/photo/$value", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "jpeg" || resp.Header.Get("Content-Type") != "image/jpeg" {
+		t.Fatalf("unexpected response %q %v", b, resp.Header.Get("Content-Type"))
+	}
+}
+
+// legacyClient implements only client.Client, without a Config or context-aware refreshes.
+type legacyClient struct {
+	refreshes int
+}
+
+func (c *legacyClient) Credentials() *client.RequestCredentials { return &client.RequestCredentials{} }
+func (c *legacyClient) InitializeCredentials() error            { return nil }
+func (c *legacyClient) RefreshCredentials() error               { c.refreshes++; return nil }
+
+func TestLegacyClientUsesDefaults(t *testing.T) {
+	c := &legacyClient{}
+	if got := GraphURL(c, "v1.0/me", nil); got != client.DefaultGraphRootURL+"v1.0/me" {
+		t.Fatalf("expected the default graph root, got %v", got)
+	}
+	if err := clientRefresh(context.Background(), c); err != nil || c.refreshes != 1 {
+		t.Fatalf("expected RefreshCredentials to be called, got %v after %v refreshes", err, c.refreshes)
+	}
+}
@@ -0,0 +1,128 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/mhoc/msgoraph/client"
+)
+
+// pageResponse is the envelope every paginated collection in the Graph API is returned in.
+type pageResponse struct {
+	Count     *int64            `json:"@odata.count"`
+	NextPage  string            `json:"@odata.nextLink"`
+	DeltaLink string            `json:"@odata.deltaLink"`
+	Value     []json.RawMessage `json:"value"`
+}
+
+// PageIterator lazily walks every item of a paginated collection, requesting the page behind each
+// @odata.nextLink only once the items before it have been consumed. Call Next until it returns
+// false, reading each item with Value, then check Err:
+//
+//	it := internal.NewPageIterator(ctx, client, url)
+//	for it.Next() {
+//		item := it.Value()
+//	}
+//	if err := it.Err(); err != nil {
+//	}
+type PageIterator struct {
+	client    client.Client
+	count     *int64
+	ctx       context.Context
+	deltaLink string
+	err       error
+	header    http.Header
+	index     int
+	nextURL   string
+	page      []json.RawMessage
+	pageURL   string
+}
+
+// NewPageIterator creates an iterator over the collection at the given fully formed url. Page
+// sizing is done through the $top parameter of that url.
+func NewPageIterator(ctx context.Context, client client.Client, url string) *PageIterator {
+	return NewPageIteratorWithHeader(ctx, client, url, nil)
+}
+
+// NewPageIteratorWithHeader is NewPageIterator, with the given headers added to every page request.
+func NewPageIteratorWithHeader(ctx context.Context, client client.Client, url string, header http.Header) *PageIterator {
+	return &PageIterator{client: client, ctx: ctx, header: header, nextURL: url}
+}
+
+// Next advances the iterator to the next item, requesting the next page if needed. It returns false
+// when the collection is exhausted or a request fails.
+func (it *PageIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	for it.index+1 >= len(it.page) {
+		if it.nextURL == "" {
+			it.page = nil
+			return false
+		}
+		b, err := BasicGraphRequestWithHeader(it.ctx, it.client, "GET", it.nextURL, it.header)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		var data pageResponse
+		err = json.Unmarshal(b, &data)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		it.page = data.Value
+		it.index = -1
+		it.pageURL = it.nextURL
+		it.nextURL = data.NextPage
+		if data.DeltaLink != "" {
+			it.deltaLink = data.DeltaLink
+		}
+		if data.Count != nil {
+			it.count = data.Count
+		}
+	}
+	it.index++
+	return true
+}
+
+// Value returns the raw json of the current item.
+func (it *PageIterator) Value() json.RawMessage {
+	if it.index < 0 || it.index >= len(it.page) {
+		return nil
+	}
+	return it.page[it.index]
+}
+
+// Err returns the error which stopped iteration, if any.
+func (it *PageIterator) Err() error {
+	return it.err
+}
+
+// Count returns the @odata.count of the collection, which is only returned when $count was
+// requested, and whether it was present. It's available once the first page has been requested.
+func (it *PageIterator) Count() (int64, bool) {
+	if it.count == nil {
+		return 0, false
+	}
+	return *it.count, true
+}
+
+// NextLink returns the url of the next page which has not yet been requested. Iteration can be
+// resumed later from this url with a new iterator.
+func (it *PageIterator) NextLink() string {
+	return it.nextURL
+}
+
+// PageLink returns the url of the page the current item is on. Iteration can be restarted from the
+// beginning of that page with a new iterator, such as when an item on it couldn't be handled.
+func (it *PageIterator) PageLink() string {
+	return it.pageURL
+}
+
+// DeltaLink returns the @odata.deltaLink handed back on the last page of a delta query. It's empty
+// until the final page has been requested.
+func (it *PageIterator) DeltaLink() string {
+	return it.deltaLink
+}
@@ -0,0 +1,60 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPageIterator(t *testing.T) {
+	var srv *httptest.Server
+	requests := 0
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.Query().Get("page") {
+		case "":
+			fmt.Fprintf(w, `{"@odata.nextLink":"%v/v1.0/users?page=2","value":[{"id":"1"},{"id":"2"}]}`, srv.URL)
+		case "2":
+			fmt.Fprintf(w, `{"@odata.nextLink":"%v/v1.0/users?page=3","value":[]}`, srv.URL)
+		case "3":
+			fmt.Fprint(w, `{"@odata.deltaLink":"delta","value":[{"id":"3"}]}`)
+		}
+	}))
+	defer srv.Close()
+	it := NewPageIterator(context.Background(), newTestClient(srv), srv.URL+"/v1.0/users")
+	var ids []string
+	for it.Next() {
+		ids = append(ids, string(it.Value()))
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 3 || ids[2] != `{"id":"3"}` {
+		t.Fatalf("unexpected items %v", ids)
+	}
+	if it.DeltaLink() != "delta" {
+		t.Fatalf("expected the delta link from the last page, got %q", it.DeltaLink())
+	}
+	if requests != 3 {
+		t.Fatalf("expected 3 page requests, got %v", requests)
+	}
+}
+
+func TestPageIteratorStopsEarly(t *testing.T) {
+	var srv *httptest.Server
+	requests := 0
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprintf(w, `{"@odata.nextLink":"%v/v1.0/users","value":[{"id":"1"}]}`, srv.URL)
+	}))
+	defer srv.Close()
+	it := NewPageIterator(context.Background(), newTestClient(srv), srv.URL+"/v1.0/users")
+	if !it.Next() {
+		t.Fatal(it.Err())
+	}
+	if requests != 1 {
+		t.Fatalf("expected pages to be requested lazily, got %v requests", requests)
+	}
+}
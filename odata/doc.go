@@ -0,0 +1,3 @@
+// Package odata implements a typed builder for the OData query parameters supported by the
+// Microsoft Graph API, such as $filter, $orderby and $search.
+package odata
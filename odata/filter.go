@@ -0,0 +1,173 @@
+package odata
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Filter is a $filter expression. Build them with the functions in this package, such as Eq and
+// StartsWith, and combine them with And, Or and Not. See
+// https://docs.microsoft.com/en-us/graph/query-parameters#filter-parameter.
+type Filter struct {
+	advanced bool
+	expr     string
+}
+
+// Raw wraps an expression which the builder functions don't cover. If the expression relies on
+// advanced query capabilities, such as endsWith or ne, use RawAdvanced instead.
+func Raw(expr string) Filter {
+	return Filter{expr: expr}
+}
+
+// RawAdvanced wraps an expression which requires the Graph API's advanced query capabilities. A
+// query carrying it is sent with the ConsistencyLevel: eventual header and $count=true.
+func RawAdvanced(expr string) Filter {
+	return Filter{advanced: true, expr: expr}
+}
+
+// String returns the expression as it is sent in the $filter parameter.
+func (f Filter) String() string {
+	return f.expr
+}
+
+// Advanced returns true if the expression requires the Graph API's advanced query capabilities.
+func (f Filter) Advanced() bool {
+	return f.advanced
+}
+
+// Eq matches when the property equals the value.
+func Eq(property string, value interface{}) Filter {
+	return comparison(property, "eq", value)
+}
+
+// Ne matches when the property does not equal the value. This is an advanced query.
+func Ne(property string, value interface{}) Filter {
+	f := comparison(property, "ne", value)
+	f.advanced = true
+	return f
+}
+
+// Gt matches when the property is greater than the value.
+func Gt(property string, value interface{}) Filter {
+	return comparison(property, "gt", value)
+}
+
+// Ge matches when the property is greater than or equal to the value.
+func Ge(property string, value interface{}) Filter {
+	return comparison(property, "ge", value)
+}
+
+// Lt matches when the property is less than the value.
+func Lt(property string, value interface{}) Filter {
+	return comparison(property, "lt", value)
+}
+
+// Le matches when the property is less than or equal to the value.
+func Le(property string, value interface{}) Filter {
+	return comparison(property, "le", value)
+}
+
+// StartsWith matches when the string property starts with the prefix.
+func StartsWith(property string, prefix string) Filter {
+	return Filter{expr: fmt.Sprintf("startswith(%v,%v)", property, Literal(prefix))}
+}
+
+// EndsWith matches when the string property ends with the suffix. This is an advanced query.
+func EndsWith(property string, suffix string) Filter {
+	return Filter{advanced: true, expr: fmt.Sprintf("endswith(%v,%v)", property, Literal(suffix))}
+}
+
+// In matches when the property equals any of the values.
+func In(property string, values ...interface{}) Filter {
+	literals := make([]string, len(values))
+	for i, v := range values {
+		literals[i] = Literal(v)
+	}
+	return Filter{expr: fmt.Sprintf("%v in (%v)", property, strings.Join(literals, ","))}
+}
+
+// Any matches when any member of the collection property satisfies the filter, which refers to
+// the member by the given variable name:
+//
+//	odata.Any("proxyAddresses", "p", odata.StartsWith("p", "smtp:"))
+func Any(collection string, variable string, f Filter) Filter {
+	return lambda(collection, "any", variable, f)
+}
+
+// All matches when every member of the collection property satisfies the filter, which refers to
+// the member by the given variable name.
+func All(collection string, variable string, f Filter) Filter {
+	return lambda(collection, "all", variable, f)
+}
+
+// And matches when every one of the filters match.
+func And(filters ...Filter) Filter {
+	return join("and", filters)
+}
+
+// Or matches when any one of the filters match.
+func Or(filters ...Filter) Filter {
+	return join("or", filters)
+}
+
+// Not negates the filter. This is an advanced query.
+func Not(f Filter) Filter {
+	return Filter{advanced: true, expr: fmt.Sprintf("not(%v)", f.expr)}
+}
+
+// Literal formats a value as an OData literal: strings, including named string types, are single
+// quoted and escaped, times are formatted as RFC3339, and nil becomes null.
+func Literal(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "'" + strings.Replace(v, "'", "''", -1) + "'"
+	case time.Time:
+		return v.UTC().Format(time.RFC3339)
+	case *time.Time:
+		if v == nil {
+			return "null"
+		}
+		return v.UTC().Format(time.RFC3339)
+	case fmt.Stringer:
+		return Literal(v.String())
+	}
+	if rv := reflect.ValueOf(value); rv.Kind() == reflect.String {
+		return Literal(rv.String())
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+func comparison(property string, operator string, value interface{}) Filter {
+	return Filter{expr: fmt.Sprintf("%v %v %v", property, operator, Literal(value))}
+}
+
+func lambda(collection string, operator string, variable string, f Filter) Filter {
+	return Filter{
+		advanced: f.advanced,
+		expr:     fmt.Sprintf("%v/%v(%v:%v)", collection, operator, variable, f.expr),
+	}
+}
+
+func join(operator string, filters []Filter) Filter {
+	switch len(filters) {
+	case 0:
+		return Filter{}
+	case 1:
+		return filters[0]
+	}
+	joined := Filter{}
+	exprs := make([]string, 0, len(filters))
+	for _, f := range filters {
+		if f.expr == "" {
+			continue
+		}
+		joined.advanced = joined.advanced || f.advanced
+		exprs = append(exprs, "("+f.expr+")")
+	}
+	joined.expr = strings.Join(exprs, " "+operator+" ")
+	return joined
+}
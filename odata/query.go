@@ -0,0 +1,150 @@
+package odata
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Query collects the OData query parameters of a request. Every method returns the query so calls
+// can be chained:
+//
+//	q := odata.NewQuery().
+//		Select("id", "displayName").
+//		Filter(odata.StartsWith("displayName", "A")).
+//		OrderBy("displayName").
+//		Top(50)
+//
+// Queries which use $search, $count or an advanced filter are sent with the
+// ConsistencyLevel: eventual header the Graph API requires for them. See
+// https://docs.microsoft.com/en-us/graph/aad-advanced-queries.
+type Query struct {
+	count    bool
+	eventual bool
+	expand   []string
+	filter   Filter
+	orderBy  []string
+	search   []string
+	selected []string
+	top      int
+}
+
+// NewQuery creates an empty query.
+func NewQuery() *Query {
+	return &Query{}
+}
+
+// Select sets the properties projected onto the returned resources ($select).
+func (q *Query) Select(properties ...string) *Query {
+	q.selected = append(q.selected, properties...)
+	return q
+}
+
+// Filter sets the $filter expression. Calling it more than once ands the filters together.
+func (q *Query) Filter(f Filter) *Query {
+	if q.filter.expr == "" {
+		q.filter = f
+	} else {
+		q.filter = And(q.filter, f)
+	}
+	return q
+}
+
+// OrderBy sorts ascending by the given property ($orderby). Call it again for secondary sorts.
+func (q *Query) OrderBy(property string) *Query {
+	q.orderBy = append(q.orderBy, property)
+	return q
+}
+
+// OrderByDesc sorts descending by the given property ($orderby).
+func (q *Query) OrderByDesc(property string) *Query {
+	q.orderBy = append(q.orderBy, property+" desc")
+	return q
+}
+
+// Search adds a clause matching resources whose property contains the term ($search). Calling it
+// more than once ands the clauses together. Search requires eventual consistency.
+func (q *Query) Search(property string, term string) *Query {
+	clause := strings.Replace(fmt.Sprintf("%v:%v", property, term), `"`, `\"`, -1)
+	q.search = append(q.search, `"`+clause+`"`)
+	return q
+}
+
+// Count asks for the total number of matching resources to be returned alongside the first page
+// ($count). Count requires eventual consistency.
+func (q *Query) Count() *Query {
+	q.count = true
+	return q
+}
+
+// Expand includes the given relationships inline with each returned resource ($expand).
+func (q *Query) Expand(relationships ...string) *Query {
+	q.expand = append(q.expand, relationships...)
+	return q
+}
+
+// Top sets the number of resources returned per page ($top).
+func (q *Query) Top(n int) *Query {
+	q.top = n
+	return q
+}
+
+// EventualConsistency sends the query with the ConsistencyLevel: eventual header even if nothing
+// else in it requires it, for advanced queries that the builder can't detect.
+func (q *Query) EventualConsistency() *Query {
+	q.eventual = true
+	return q
+}
+
+// Selected returns the properties which will be projected, if any.
+func (q *Query) Selected() []string {
+	return q.selected
+}
+
+// RequiresEventualConsistency returns true if the query has to be sent with the
+// ConsistencyLevel: eventual header.
+func (q *Query) RequiresEventualConsistency() bool {
+	return q != nil && (q.eventual || q.count || len(q.search) > 0 || q.filter.advanced)
+}
+
+// Values returns the query parameters to send with the request.
+func (q *Query) Values() url.Values {
+	v := url.Values{}
+	if q == nil {
+		return v
+	}
+	if len(q.selected) > 0 {
+		v.Set("$select", strings.Join(q.selected, ","))
+	}
+	if q.filter.expr != "" {
+		v.Set("$filter", q.filter.expr)
+	}
+	if len(q.orderBy) > 0 {
+		v.Set("$orderby", strings.Join(q.orderBy, ","))
+	}
+	if len(q.search) > 0 {
+		v.Set("$search", strings.Join(q.search, " AND "))
+	}
+	if len(q.expand) > 0 {
+		v.Set("$expand", strings.Join(q.expand, ","))
+	}
+	if q.top > 0 {
+		v.Set("$top", strconv.Itoa(q.top))
+	}
+	// Advanced filters are only honored by the Graph API when $count is requested as well.
+	if q.count || q.filter.advanced {
+		v.Set("$count", "true")
+	}
+	return v
+}
+
+// Header returns the request headers the query has to be sent with.
+func (q *Query) Header() http.Header {
+	h := http.Header{}
+	if q.RequiresEventualConsistency() {
+		h.Set("ConsistencyLevel", "eventual")
+	}
+	return h
+}
@@ -0,0 +1,62 @@
+package odata
+
+import (
+	"testing"
+)
+
+// userType is a named string type without a String method, like the enums of callers.
+type userType string
+
+func TestFilter(t *testing.T) {
+	cases := []struct {
+		filter   Filter
+		expected string
+		advanced bool
+	}{
+		{Eq("department", "R&D"), "department eq 'R&D'", false},
+		{Eq("displayName", "O'Brien"), "displayName eq 'O''Brien'", false},
+		{Eq("accountEnabled", true), "accountEnabled eq true", false},
+		{Eq("userType", userType("Guest' or true")), "userType eq 'Guest'' or true'", false},
+		{StartsWith("displayName", "A"), "startswith(displayName,'A')", false},
+		{In("city", "Paris", "Oslo"), "city in ('Paris','Oslo')", false},
+		{Any("proxyAddresses", "p", StartsWith("p", "smtp:")), "proxyAddresses/any(p:startswith(p,'smtp:'))", false},
+		{And(Eq("city", "Oslo"), Ne("jobTitle", nil)), "(city eq 'Oslo') and (jobTitle ne null)", true},
+		{Or(EndsWith("mail", "@contoso.com"), Eq("userType", "Guest")), "(endswith(mail,'@contoso.com')) or (userType eq 'Guest')", true},
+	}
+	for _, c := range cases {
+		if c.filter.String() != c.expected {
+			t.Errorf("expected %q, got %q", c.expected, c.filter.String())
+		}
+		if c.filter.Advanced() != c.advanced {
+			t.Errorf("expected %q to have advanced=%v", c.expected, c.advanced)
+		}
+	}
+}
+
+func TestQuery(t *testing.T) {
+	q := NewQuery().
+		Select("id", "displayName").
+		Filter(StartsWith("displayName", "A")).
+		OrderBy("displayName").
+		Expand("manager").
+		Top(10)
+	v := q.Values()
+	if v.Get("$select") != "id,displayName" || v.Get("$filter") != "startswith(displayName,'A')" ||
+		v.Get("$orderby") != "displayName" || v.Get("$expand") != "manager" || v.Get("$top") != "10" {
+		t.Fatalf("unexpected query parameters %v", v)
+	}
+	if q.Header().Get("ConsistencyLevel") != "" || v.Get("$count") != "" {
+		t.Fatalf("a basic query should not require eventual consistency")
+	}
+	q.Search("displayName", "room")
+	if q.Header().Get("ConsistencyLevel") != "eventual" {
+		t.Fatalf("a search query should require eventual consistency")
+	}
+	if got := q.Values().Get("$search"); got != `"displayName:room"` {
+		t.Fatalf("unexpected $search %v", got)
+	}
+	advanced := NewQuery().Filter(EndsWith("mail", "@contoso.com"))
+	if advanced.Values().Get("$count") != "true" || advanced.Header().Get("ConsistencyLevel") != "eventual" {
+		t.Fatalf("an advanced filter should be sent with $count and eventual consistency")
+	}
+}
@@ -149,3 +149,17 @@ func (s Scopes) QueryString() string {
 	}
 	return qs
 }
+
+// PartitionByConsent splits a list of scopes into those a user can consent to on their own and
+// those which require an administrator to consent on behalf of the tenant. Application permissions
+// always require admin consent, whether or not AdminConsentRequired is set on them.
+func (s Scopes) PartitionByConsent() (userConsentable Scopes, adminRequired Scopes) {
+	for _, scope := range s {
+		if scope.AdminConsentRequired || scope.Type == PermissionTypeApplication {
+			adminRequired = append(adminRequired, scope)
+		} else {
+			userConsentable = append(userConsentable, scope)
+		}
+	}
+	return userConsentable, adminRequired
+}
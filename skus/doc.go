@@ -0,0 +1,3 @@
+// Package skus implements functionality surrounding the licenses, or SKUs, a tenant has subscribed
+// to in the Microsoft Graph API.
+package skus
@@ -0,0 +1,149 @@
+package skus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mhoc/msgoraph/client"
+	"github.com/mhoc/msgoraph/common"
+	"github.com/mhoc/msgoraph/internal"
+)
+
+// listSubscribedSkusPermissions are the permissions ListSubscribedSkus accepts, least privileged
+// first, as checked by the permission preflight when client.Config.PermissionPreflight is set.
+var listSubscribedSkusPermissions = []string{"Organization.Read.All", "Directory.Read.All", "Organization.ReadWrite.All", "Directory.ReadWrite.All", "Directory.AccessAsUser.All"}
+
+// LicenseUnits counts the licenses of a subscribed SKU in each state of the subscription.
+type LicenseUnits struct {
+	Enabled   int `json:"enabled"`
+	Suspended int `json:"suspended"`
+	Warning   int `json:"warning"`
+}
+
+// SubscribedSku is a license the tenant has subscribed to, such as ENTERPRISEPACK for Office 365
+// E3, along with how many units of it are bought and assigned. Interpreted from this API
+// documentation https://docs.microsoft.com/en-us/graph/api/resources/subscribedsku
+type SubscribedSku struct {
+	AppliesTo        string                   `json:"appliesTo"`
+	CapabilityStatus string                   `json:"capabilityStatus"`
+	ConsumedUnits    int                      `json:"consumedUnits"`
+	ID               string                   `json:"id"`
+	PrepaidUnits     LicenseUnits             `json:"prepaidUnits"`
+	ServicePlans     []common.ServicePlanInfo `json:"servicePlans"`
+	SKUID            string                   `json:"skuId"`
+	SKUPartNumber    string                   `json:"skuPartNumber"`
+}
+
+// AvailableUnits returns how many more licenses of the SKU can be assigned.
+func (s SubscribedSku) AvailableUnits() int {
+	return s.PrepaidUnits.Enabled - s.ConsumedUnits
+}
+
+// ServicePlan returns the service plan of the SKU with the given name or id, such as
+// "EXCHANGE_S_STANDARD".
+func (s SubscribedSku) ServicePlan(nameOrID string) (common.ServicePlanInfo, bool) {
+	for _, p := range s.ServicePlans {
+		if strings.EqualFold(p.ServicePlanName, nameOrID) || strings.EqualFold(p.ServicePlanID, nameOrID) {
+			return p, true
+		}
+	}
+	return common.ServicePlanInfo{}, false
+}
+
+// ServicePlanIDs returns the ids of the service plans of the SKU with the given names or ids, for
+// disabling them with users.AssignedLicense.WithPlansDisabled. It fails if the SKU doesn't include
+// one of the plans.
+func (s SubscribedSku) ServicePlanIDs(namesOrIDs ...string) ([]string, error) {
+	ids := make([]string, len(namesOrIDs))
+	for i, name := range namesOrIDs {
+		p, ok := s.ServicePlan(name)
+		if !ok {
+			return nil, fmt.Errorf("sku %v has no service plan %v", s.SKUPartNumber, name)
+		}
+		ids[i] = p.ServicePlanID
+	}
+	return ids, nil
+}
+
+// DisabledPlansExcept returns the ids of every service plan of the SKU other than those with the
+// given names or ids, for assigning the SKU with only those plans enabled. It fails if the SKU
+// doesn't include one of the plans.
+func (s SubscribedSku) DisabledPlansExcept(namesOrIDs ...string) ([]string, error) {
+	enabled, err := s.ServicePlanIDs(namesOrIDs...)
+	if err != nil {
+		return nil, err
+	}
+	disabled := []string{}
+	for _, p := range s.ServicePlans {
+		keep := false
+		for _, id := range enabled {
+			if strings.EqualFold(id, p.ServicePlanID) {
+				keep = true
+				break
+			}
+		}
+		if !keep {
+			disabled = append(disabled, p.ServicePlanID)
+		}
+	}
+	return disabled, nil
+}
+
+// ServiceContext represents a namespace under which all of the operations against the subscribed
+// skus of a tenant are accessed.
+type ServiceContext struct {
+	client client.Client
+}
+
+// Service creates a new skus.ServiceContext with the given authentication credentials.
+func Service(client client.Client) *ServiceContext {
+	return &ServiceContext{client: client}
+}
+
+// ListSubscribedSkus returns every SKU the tenant has subscribed to.
+func (s *ServiceContext) ListSubscribedSkus() ([]SubscribedSku, error) {
+	return s.ListSubscribedSkusContext(context.Background())
+}
+
+// ListSubscribedSkusContext is ListSubscribedSkus, bound to the given context.
+func (s *ServiceContext) ListSubscribedSkusContext(ctx context.Context) ([]SubscribedSku, error) {
+	err := client.CheckPermissions(ctx, s.client, "skus.ListSubscribedSkus", listSubscribedSkusPermissions...)
+	if err != nil {
+		return nil, err
+	}
+	b, err := internal.GraphRequestContext(ctx, s.client, "GET", "v1.0/subscribedSkus", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	var data struct {
+		Value []SubscribedSku `json:"value"`
+	}
+	err = json.Unmarshal(b, &data)
+	if err != nil {
+		return nil, err
+	}
+	return data.Value, nil
+}
+
+// GetSubscribedSkuByPartNumber returns the SKU the tenant has subscribed to with the given part
+// number, such as "ENTERPRISEPACK". If the tenant has no such subscription, the error satisfies
+// client.IsNotFound.
+func (s *ServiceContext) GetSubscribedSkuByPartNumber(partNumber string) (SubscribedSku, error) {
+	return s.GetSubscribedSkuByPartNumberContext(context.Background(), partNumber)
+}
+
+// GetSubscribedSkuByPartNumberContext is GetSubscribedSkuByPartNumber, bound to the given context.
+func (s *ServiceContext) GetSubscribedSkuByPartNumberContext(ctx context.Context, partNumber string) (SubscribedSku, error) {
+	skus, err := s.ListSubscribedSkusContext(ctx)
+	if err != nil {
+		return SubscribedSku{}, err
+	}
+	for _, sku := range skus {
+		if strings.EqualFold(sku.SKUPartNumber, partNumber) {
+			return sku, nil
+		}
+	}
+	return SubscribedSku{}, fmt.Errorf("no subscription to sku %v: %w", partNumber, client.ErrNotFound)
+}
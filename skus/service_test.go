@@ -0,0 +1,35 @@
+package skus
+
+import (
+	"testing"
+
+	"github.com/mhoc/msgoraph/common"
+)
+
+func TestDisabledPlansExcept(t *testing.T) {
+	sku := SubscribedSku{
+		SKUPartNumber: "ENTERPRISEPACK",
+		ServicePlans: []common.ServicePlanInfo{
+			{ServicePlanID: "efb87545-963c-4e0d-99df-69c6916d9eb0", ServicePlanName: "EXCHANGE_S_ENTERPRISE"},
+			{ServicePlanID: "5dbe027f-2339-4123-9542-606e4d348a72", ServicePlanName: "SHAREPOINTENTERPRISE"},
+			{ServicePlanID: "57ff2da0-773e-42df-b2af-ffb7a2317929", ServicePlanName: "TEAMS1"},
+		},
+	}
+	disabled, err := sku.DisabledPlansExcept("exchange_s_enterprise", "57FF2DA0-773E-42DF-B2AF-FFB7A2317929")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(disabled) != 1 || disabled[0] != "5dbe027f-2339-4123-9542-606e4d348a72" {
+		t.Fatalf("expected only sharepoint to be disabled, got %v", disabled)
+	}
+	disabled, err = sku.DisabledPlansExcept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(disabled) != len(sku.ServicePlans) {
+		t.Fatalf("expected every plan to be disabled, got %v", disabled)
+	}
+	if _, err := sku.DisabledPlansExcept("YAMMER_ENTERPRISE"); err == nil {
+		t.Fatal("expected an error for a plan the sku doesn't include")
+	}
+}
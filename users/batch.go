@@ -0,0 +1,166 @@
+package users
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/mhoc/msgoraph/internal"
+)
+
+// Batch collects user requests which are sent to the Graph API together as JSON batches, rather
+// than one at a time. Create one with ServiceContext.NewBatch, queue requests on it, then call
+// Execute; the results are available on the items returned when each request was queued. Any
+// number of requests can be queued, they're split into batches of internal.MaxBatchSize as needed.
+type Batch struct {
+	items    []batchItem
+	requests []internal.BatchRequest
+	service  *ServiceContext
+}
+
+// ErrNotExecuted is matched by errors.Is for the Err of a batch item whose request was never sent,
+// or never answered, because the batch failed before reaching it.
+var ErrNotExecuted = errors.New("batch request not executed")
+
+// batchItem is implemented by every type of item returned from a Batch.
+type batchItem interface {
+	fail(err error)
+	resolve(resp internal.BatchResponse)
+}
+
+// BatchItem is the result of a single request queued on a Batch. Err is set once the batch has
+// executed if this request failed; it's a *client.GraphError when the Graph API rejected it.
+type BatchItem struct {
+	Err   error
+	batch *Batch
+	index int
+}
+
+// ID returns the id of this request within the batch.
+func (i *BatchItem) ID() string {
+	if i.batch == nil {
+		return ""
+	}
+	return i.batch.requests[i.index].ID
+}
+
+// DependsOn marks this request as depending on the requests with the given ids, which the Graph API
+// will then execute before this one. If any of them fail, this request fails as well.
+func (i *BatchItem) DependsOn(ids ...string) {
+	if i.batch == nil {
+		return
+	}
+	req := &i.batch.requests[i.index]
+	req.DependsOn = append(req.DependsOn, ids...)
+}
+
+func (i *BatchItem) fail(err error) {
+	i.Err = err
+}
+
+func (i *BatchItem) resolve(resp internal.BatchResponse) {
+	i.Err = resp.Err()
+}
+
+// UserBatchItem is the result of a queued request which responds with a user.
+type UserBatchItem struct {
+	BatchItem
+	User User
+}
+
+func (i *UserBatchItem) resolve(resp internal.BatchResponse) {
+	i.Err = resp.Err()
+	if i.Err != nil {
+		return
+	}
+	var data GetUserResponse
+	i.Err = json.Unmarshal(resp.Body, &data)
+	i.User = data.User
+}
+
+// NewBatch creates a new, empty batch of user requests.
+func (s *ServiceContext) NewBatch() *Batch {
+	return &Batch{service: s}
+}
+
+// queue adds a request to the batch and returns the BatchItem tracking it.
+func (b *Batch) queue(method string, url string, body interface{}) BatchItem {
+	b.requests = append(b.requests, internal.BatchRequest{
+		ID:     strconv.Itoa(len(b.requests) + 1),
+		Method: method,
+		URL:    url,
+		Body:   body,
+	})
+	return BatchItem{batch: b, index: len(b.requests) - 1}
+}
+
+// CreateUser queues the creation of a new user in the tenant.
+func (b *Batch) CreateUser(createUser CreateUserRequest) *UserBatchItem {
+	item := &UserBatchItem{BatchItem: b.queue("POST", "/users", createUser)}
+	b.items = append(b.items, item)
+	return item
+}
+
+// DeleteUser queues the deletion of an existing user by id or principal name.
+func (b *Batch) DeleteUser(userIDOrPrincipal string) *BatchItem {
+	item := b.queue("DELETE", fmt.Sprintf("/users/%v", userIDOrPrincipal), nil)
+	b.items = append(b.items, &item)
+	return &item
+}
+
+// GetUser queues the retrieval of a single user by id or principal name, projected with
+// UserDefaultFields.
+func (b *Batch) GetUser(userIDOrPrincipal string) *UserBatchItem {
+	return b.GetUserWithFields(userIDOrPrincipal, UserDefaultFields)
+}
+
+// GetUserWithFields queues the retrieval of a single user by id or principal name, projected with
+// the given fields. If no fields are provided, nothing is queued and the item's Err is set
+// immediately.
+func (b *Batch) GetUserWithFields(userIDOrPrincipal string, projection []Field) *UserBatchItem {
+	v, err := selectParams(projection)
+	if err != nil {
+		return &UserBatchItem{BatchItem: BatchItem{Err: err}}
+	}
+	reqURL := fmt.Sprintf("/users/%v?%v", userIDOrPrincipal, v.Encode())
+	item := &UserBatchItem{BatchItem: b.queue("GET", reqURL, nil)}
+	b.items = append(b.items, item)
+	return item
+}
+
+// UpdateUser queues an update to a user by id or principal name.
+func (b *Batch) UpdateUser(userIDOrPrincipal string, u UpdateUserRequest) *BatchItem {
+	item := b.queue("PATCH", fmt.Sprintf("/users/%v", userIDOrPrincipal), u)
+	b.items = append(b.items, &item)
+	return &item
+}
+
+// Len returns the number of requests queued on the batch.
+func (b *Batch) Len() int {
+	return len(b.requests)
+}
+
+// Execute sends every queued request to the Graph API and fills in the result of each item. The
+// returned error only reflects the failure of the batch as a whole; check Err on each item for the
+// result of the individual requests. When a batch fails part way, the items of the batches which
+// were already sent still get their results, and the remaining items get an error matching
+// ErrNotExecuted. Throttled items aren't retried; their Err satisfies client.IsThrottled.
+func (b *Batch) Execute() error {
+	return b.ExecuteContext(context.Background())
+}
+
+// ExecuteContext is Execute, bound to the given context.
+func (b *Batch) ExecuteContext(ctx context.Context) error {
+	responses, err := internal.Batch(ctx, b.service.client, "v1.0", b.requests)
+	for i, item := range b.items {
+		resp, ok := responses[b.requests[i].ID]
+		if !ok {
+			item.fail(fmt.Errorf("%w: %v", ErrNotExecuted, err))
+			continue
+		}
+		item.resolve(resp)
+	}
+	return err
+}
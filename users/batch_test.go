@@ -0,0 +1,67 @@
+package users
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/mhoc/msgoraph/client"
+	"github.com/mhoc/msgoraph/internal"
+)
+
+// newTestService returns a users service sending its requests to the given handler.
+func newTestService(t *testing.T, handler http.HandlerFunc) *ServiceContext {
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	c := client.NewStaticToken("token", time.Now().Add(time.Hour))
+	c.Config = &client.Config{GraphRootURL: srv.URL, HTTPClient: srv.Client()}
+	return Service(c)
+}
+
+func TestBatchExecuteResolvesCompletedItems(t *testing.T) {
+	posts := 0
+	s := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		posts++
+		if posts > 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"error":{"code":"ServiceUnavailable","message":"try later"}}`))
+			return
+		}
+		var body struct {
+			Requests []internal.BatchRequest `json:"requests"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		var data struct {
+			Responses []internal.BatchResponse `json:"responses"`
+		}
+		for _, req := range body.Requests {
+			data.Responses = append(data.Responses, internal.BatchResponse{ID: req.ID, Status: http.StatusNoContent})
+		}
+		json.NewEncoder(w).Encode(data)
+	})
+	b := s.NewBatch()
+	var items []*BatchItem
+	for i := 0; i < internal.MaxBatchSize+2; i++ {
+		items = append(items, b.DeleteUser("user"+strconv.Itoa(i)))
+	}
+	if err := b.Execute(); err == nil {
+		t.Fatal("expected the failure of the second batch to be returned")
+	}
+	for i, item := range items {
+		if i < internal.MaxBatchSize {
+			if item.Err != nil {
+				t.Fatalf("item %v of the completed batch has error %v", i, item.Err)
+			}
+			continue
+		}
+		if !errors.Is(item.Err, ErrNotExecuted) {
+			t.Fatalf("expected item %v to be marked not executed, got %v", i, item.Err)
+		}
+	}
+}
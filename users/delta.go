@@ -0,0 +1,145 @@
+package users
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mhoc/msgoraph/internal"
+)
+
+const (
+	// RemovedReasonChanged is the reason given for a user which was soft-deleted, and can still be
+	// restored.
+	RemovedReasonChanged = "changed"
+	// RemovedReasonDeleted is the reason given for a user which was permanently deleted.
+	RemovedReasonDeleted = "deleted"
+)
+
+// RemovedUser is a user which was removed from the tenant since the last delta sync.
+type RemovedUser struct {
+	ID     string
+	Reason string
+}
+
+// UserDelta is the set of changes to the users in a tenant since the last delta sync. See
+// https://docs.microsoft.com/en-us/graph/delta-query-users.
+type UserDelta struct {
+	// Added holds every user in the tenant when the sync was started with StartUserDelta. The Graph
+	// API doesn't distinguish between new and updated users on later syncs, so it's always empty
+	// when resuming with ResumeUserDelta.
+	Added []User
+
+	// Changed holds the users which were created or updated since the delta link the sync was
+	// resumed from. Only the changed fields of each user are guaranteed to be set, along with ID.
+	Changed []User
+
+	// Removed holds the users which were deleted since the delta link the sync was resumed from.
+	Removed []RemovedUser
+
+	// DeltaLink should be persisted and passed to ResumeUserDelta to fetch the changes made after
+	// this sync.
+	DeltaLink string
+
+	// NextLink is set instead of DeltaLink when a page request or decoding one of its users failed
+	// part way through the sync. The delta then holds the users read before the failure, and
+	// ContinueUserDelta fetches the rest from where it stopped.
+	NextLink string
+
+	// Initial is true for a sync started with StartUserDelta, whose users are returned in Added.
+	Initial bool
+}
+
+// deltaUser is a user as returned by the delta endpoint, which may carry a removal marker.
+type deltaUser struct {
+	User
+	Removed *struct {
+		Reason string `json:"reason"`
+	} `json:"@removed"`
+}
+
+// StartUserDelta begins a delta sync of the users in the tenant, returning every user in Added
+// projected with the given fields. The returned DeltaLink carries the projection along to later
+// syncs. Every page is held in memory until the sync completes, so the initial sync of a large
+// tenant holds all of its users at once; project only the fields you need.
+func (s *ServiceContext) StartUserDelta(projection []Field) (*UserDelta, error) {
+	return s.StartUserDeltaContext(context.Background(), projection)
+}
+
+// StartUserDeltaContext is StartUserDelta, bound to the given context.
+func (s *ServiceContext) StartUserDeltaContext(ctx context.Context, projection []Field) (*UserDelta, error) {
+	v, err := selectParams(projection)
+	if err != nil {
+		return nil, err
+	}
+	return s.userDelta(ctx, internal.GraphURL(s.client, "v1.0/users/delta", v), true)
+}
+
+// ResumeUserDelta fetches the changes to the users in the tenant since the sync which returned the
+// given delta link. If the delta link has expired, the Graph API responds with a 410 and a full
+// sync has to be started again with StartUserDelta.
+func (s *ServiceContext) ResumeUserDelta(deltaLink string) (*UserDelta, error) {
+	return s.ResumeUserDeltaContext(context.Background(), deltaLink)
+}
+
+// ResumeUserDeltaContext is ResumeUserDelta, bound to the given context.
+func (s *ServiceContext) ResumeUserDeltaContext(ctx context.Context, deltaLink string) (*UserDelta, error) {
+	if deltaLink == "" {
+		return nil, fmt.Errorf("no delta link provided to resume from")
+	}
+	return s.userDelta(ctx, deltaLink, false)
+}
+
+// ContinueUserDelta fetches the rest of a sync which was interrupted by a failed page, from the
+// NextLink of the partial delta returned along with the error. Users are sorted into Added or
+// Changed the same way as in the interrupted sync.
+func (s *ServiceContext) ContinueUserDelta(partial *UserDelta) (*UserDelta, error) {
+	return s.ContinueUserDeltaContext(context.Background(), partial)
+}
+
+// ContinueUserDeltaContext is ContinueUserDelta, bound to the given context.
+func (s *ServiceContext) ContinueUserDeltaContext(ctx context.Context, partial *UserDelta) (*UserDelta, error) {
+	if partial == nil || partial.NextLink == "" {
+		return nil, fmt.Errorf("no next link provided to continue from")
+	}
+	return s.userDelta(ctx, partial.NextLink, partial.Initial)
+}
+
+// userDelta pages through the delta endpoint starting at the given url, until it hands back a
+// delta link. If a page request fails, or a user on a page can't be decoded, the users read so far
+// are returned along with the error, with NextLink set to the page which failed. Continuing from a
+// page which failed to decode returns its users again; delta queries may return a user more than
+// once anyway, so callers already have to apply changes idempotently.
+func (s *ServiceContext) userDelta(ctx context.Context, url string, initial bool) (*UserDelta, error) {
+	if err := s.preflight(ctx, "UserDelta", userDeltaPermissions); err != nil {
+		return nil, err
+	}
+	delta := &UserDelta{Initial: initial}
+	it := internal.NewPageIterator(ctx, s.client, url)
+	for it.Next() {
+		var u deltaUser
+		err := json.Unmarshal(it.Value(), &u)
+		if err != nil {
+			delta.NextLink = it.PageLink()
+			return delta, fmt.Errorf("user delta interrupted, continue it from NextLink: %w", err)
+		}
+		switch {
+		case u.Removed != nil:
+			removed := RemovedUser{Reason: u.Removed.Reason}
+			if u.ID != nil {
+				removed.ID = *u.ID
+			}
+			delta.Removed = append(delta.Removed, removed)
+		case initial:
+			delta.Added = append(delta.Added, u.User)
+		default:
+			delta.Changed = append(delta.Changed, u.User)
+		}
+	}
+	if err := it.Err(); err != nil {
+		delta.NextLink = it.NextLink()
+		return delta, fmt.Errorf("user delta interrupted, continue it from NextLink: %w", err)
+	}
+	delta.DeltaLink = it.DeltaLink()
+	return delta, nil
+}
@@ -0,0 +1,69 @@
+package users
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestUserDeltaContinuesAfterFailedPage(t *testing.T) {
+	failures := 1
+	s := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		root := "http://" + r.Host
+		switch r.URL.Query().Get("$skiptoken") {
+		case "":
+			fmt.Fprintf(w, `{"value":[{"id":"1"}],"@odata.nextLink":"%v/v1.0/users/delta?$skiptoken=2"}`, root)
+		case "2":
+			if failures > 0 {
+				failures--
+				w.WriteHeader(http.StatusServiceUnavailable)
+				w.Write([]byte(`{"error":{"code":"ServiceUnavailable","message":"try later"}}`))
+				return
+			}
+			fmt.Fprintf(w, `{"value":[{"id":"2"},{"id":"3","@removed":{"reason":"deleted"}}],"@odata.deltaLink":"%v/v1.0/users/delta?$deltatoken=3"}`, root)
+		}
+	})
+	partial, err := s.StartUserDelta([]Field{FieldID})
+	if err == nil {
+		t.Fatal("expected the failed page to be returned as an error")
+	}
+	if partial == nil || len(partial.Added) != 1 || *partial.Added[0].ID != "1" || partial.DeltaLink != "" {
+		t.Fatalf("expected the users of the first page, got %+v", partial)
+	}
+	rest, err := s.ContinueUserDelta(partial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rest.Added) != 1 || *rest.Added[0].ID != "2" || len(rest.Changed) != 0 {
+		t.Fatalf("expected the rest of the initial sync in Added, got %+v", rest)
+	}
+	if len(rest.Removed) != 1 || rest.Removed[0].ID != "3" || rest.Removed[0].Reason != RemovedReasonDeleted {
+		t.Fatalf("unexpected removed users %+v", rest.Removed)
+	}
+	if rest.DeltaLink == "" || rest.NextLink != "" {
+		t.Fatalf("expected a delta link once the sync completed, got %q and %q", rest.DeltaLink, rest.NextLink)
+	}
+}
+
+func TestUserDeltaKeepsUsersReadBeforeDecodeFailure(t *testing.T) {
+	s := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		root := "http://" + r.Host
+		switch r.URL.Query().Get("$skiptoken") {
+		case "":
+			fmt.Fprintf(w, `{"value":[{"id":"1"}],"@odata.nextLink":"%v/v1.0/users/delta?$skiptoken=2"}`, root)
+		case "2":
+			w.Write([]byte(`{"value":[{"id":"2"},{"id":3}]}`))
+		}
+	})
+	partial, err := s.StartUserDelta([]Field{FieldID})
+	if err == nil {
+		t.Fatal("expected the undecodable user to be returned as an error")
+	}
+	if partial == nil || len(partial.Added) != 2 || *partial.Added[1].ID != "2" {
+		t.Fatalf("expected the users read before the failure, got %+v", partial)
+	}
+	if !strings.HasSuffix(partial.NextLink, "$skiptoken=2") || partial.DeltaLink != "" {
+		t.Fatalf("expected to continue from the page which failed, got %q", partial.NextLink)
+	}
+}
@@ -1,3 +1,6 @@
 // Package users implements functionality surrounding accessing and mutating user data in
 // the Microsoft Graph API.
+//
+// Methods ending in Context take a context which bounds their requests. For the iterators of
+// paginated collections, cancelling the context stops pagination at the next page request.
 package users
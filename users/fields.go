@@ -31,6 +31,8 @@ const (
 	FieldDepartment Field = "department"
 	// FieldDisplayName displayName
 	FieldDisplayName Field = "displayName"
+	// FieldEmployeeID employeeId
+	FieldEmployeeID Field = "employeeId"
 	// FieldGivenName givenName
 	FieldGivenName Field = "givenName"
 	// FieldHireDate hireDate
@@ -53,6 +55,8 @@ const (
 	FieldMySite Field = "mySite"
 	// FieldOfficeLocation officeLocation
 	FieldOfficeLocation Field = "officeLocation"
+	// FieldOnPremisesExtensionAttributes onPremisesExtensionAttributes
+	FieldOnPremisesExtensionAttributes Field = "onPremisesExtensionAttributes"
 	// FieldOnPremisesImmutableID onPremisesImmutableId
 	FieldOnPremisesImmutableID Field = "onPremisesImmutableId"
 	// FieldOnPremisesLastSyncDateTime onPremisesLastSyncDateTime
@@ -61,6 +65,8 @@ const (
 	FieldOnPremisesSecurityIdentifier Field = "onPremisesSecurityIdentifier"
 	// FieldOnPremisesSyncEnabled onPremisesSyncEnabled
 	FieldOnPremisesSyncEnabled Field = "onPremisesSyncEnabled"
+	// FieldOtherMails otherMails
+	FieldOtherMails Field = "otherMails"
 	// FieldPasswordPolicies passwordPolicies
 	FieldPasswordPolicies Field = "passwordPolicies"
 	// FieldPasswordProfile passwordProfile
@@ -112,6 +118,7 @@ var (
 		FieldCountry,
 		FieldDepartment,
 		FieldDisplayName,
+		FieldEmployeeID,
 		FieldGivenName,
 		FieldHireDate,
 		FieldIMAddresses,
@@ -123,10 +130,12 @@ var (
 		FieldMobilePhone,
 		FieldMySite,
 		FieldOfficeLocation,
+		FieldOnPremisesExtensionAttributes,
 		FieldOnPremisesImmutableID,
 		FieldOnPremisesLastSyncDateTime,
 		FieldOnPremisesSecurityIdentifier,
 		FieldOnPremisesSyncEnabled,
+		FieldOtherMails,
 		FieldPasswordPolicies,
 		FieldPasswordProfile,
 		FieldPastProjects,
@@ -161,3 +170,12 @@ var (
 		FieldUserPrincipalName,
 	}
 )
+
+// FieldNames converts a list of fields into their names, such as for use with odata.Query.Select.
+func FieldNames(projection []Field) []string {
+	names := make([]string, len(projection))
+	for i, f := range projection {
+		names[i] = string(f)
+	}
+	return names
+}
@@ -0,0 +1,96 @@
+package users
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"github.com/mhoc/msgoraph/internal"
+	"github.com/mhoc/msgoraph/odata"
+)
+
+// UserIterator lazily walks a collection of users, only requesting each page from the Graph API
+// once the users before it have been consumed. Stopping early simply means no further pages are
+// requested.
+//
+//	it := users.Service(c).IterateUsersWithFields(users.UserDefaultFields, 100)
+//	for it.Next() {
+//		u := it.User()
+//	}
+//	if err := it.Err(); err != nil {
+//	}
+type UserIterator struct {
+	err   error
+	pages *internal.PageIterator
+	user  User
+}
+
+// Next advances to the next user, returning false when there are none left or an error occurred.
+func (it *UserIterator) Next() bool {
+	if it.err != nil || !it.pages.Next() {
+		return false
+	}
+	it.user = User{}
+	if err := json.Unmarshal(it.pages.Value(), &it.user); err != nil {
+		it.err = err
+		return false
+	}
+	return true
+}
+
+// User returns the current user.
+func (it *UserIterator) User() User {
+	return it.user
+}
+
+// Err returns the error which stopped iteration, if any.
+func (it *UserIterator) Err() error {
+	if it.err != nil {
+		return it.err
+	}
+	return it.pages.Err()
+}
+
+// Count returns the total number of users matching the query, and whether it's known. It's only
+// known when the query asked for it with odata.Query.Count, and once Next has been called.
+func (it *UserIterator) Count() (int64, bool) {
+	return it.pages.Count()
+}
+
+// IterateUsersWithFields returns an iterator over the users in the tenant, projected with the given
+// fields. pageSize sets how many users are requested per page; zero leaves it to the Graph API.
+func (s *ServiceContext) IterateUsersWithFields(projection []Field, pageSize int) *UserIterator {
+	return s.IterateUsersWithFieldsContext(context.Background(), projection, pageSize)
+}
+
+// IterateUsersWithFieldsContext is IterateUsersWithFields, with every page request bound to the
+// given context.
+func (s *ServiceContext) IterateUsersWithFieldsContext(ctx context.Context, projection []Field, pageSize int) *UserIterator {
+	if err := s.preflight(ctx, "ListUsers", listUsersPermissions); err != nil {
+		return &UserIterator{err: err, pages: internal.NewPageIterator(ctx, s.client, "")}
+	}
+	v, err := selectParams(projection)
+	if err != nil {
+		return &UserIterator{err: err, pages: internal.NewPageIterator(ctx, s.client, "")}
+	}
+	if pageSize > 0 {
+		v.Set("$top", strconv.Itoa(pageSize))
+	}
+	return &UserIterator{pages: internal.NewPageIterator(ctx, s.client, internal.GraphURL(s.client, "v1.0/users", v))}
+}
+
+// IterateUsersWithQuery returns an iterator over the users in the tenant matching the given query.
+// If the query selects nothing, the users are projected with UserDefaultFields.
+func (s *ServiceContext) IterateUsersWithQuery(q *odata.Query) *UserIterator {
+	return s.IterateUsersWithQueryContext(context.Background(), q)
+}
+
+// IterateUsersWithQueryContext is IterateUsersWithQuery, with every page request bound to the
+// given context.
+func (s *ServiceContext) IterateUsersWithQueryContext(ctx context.Context, q *odata.Query) *UserIterator {
+	if err := s.preflight(ctx, "ListUsers", listUsersPermissions); err != nil {
+		return &UserIterator{err: err, pages: internal.NewPageIterator(ctx, s.client, "")}
+	}
+	reqURL := internal.GraphURL(s.client, "v1.0/users", queryParams(q))
+	return &UserIterator{pages: internal.NewPageIteratorWithHeader(ctx, s.client, reqURL, q.Header())}
+}
@@ -1,7 +1,132 @@
 package users
 
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mhoc/msgoraph/common"
+	"github.com/mhoc/msgoraph/internal"
+)
+
 // AssignedLicense represents a license assigned to a user
 type AssignedLicense struct {
 	DisabledPlans []string `json:"disabledPlans"`
 	SKUID         string   `json:"skuId"`
 }
+
+// WithPlansDisabled returns a copy of the license with the service plans of the given ids disabled,
+// in addition to those already disabled.
+func (l AssignedLicense) WithPlansDisabled(servicePlanIDs ...string) AssignedLicense {
+	disabled := append([]string{}, l.DisabledPlans...)
+	for _, id := range servicePlanIDs {
+		if !containsPlan(disabled, id) {
+			disabled = append(disabled, id)
+		}
+	}
+	return AssignedLicense{DisabledPlans: disabled, SKUID: l.SKUID}
+}
+
+// WithPlansEnabled returns a copy of the license with the service plans of the given ids no longer
+// disabled.
+func (l AssignedLicense) WithPlansEnabled(servicePlanIDs ...string) AssignedLicense {
+	disabled := []string{}
+	for _, id := range l.DisabledPlans {
+		if !containsPlan(servicePlanIDs, id) {
+			disabled = append(disabled, id)
+		}
+	}
+	return AssignedLicense{DisabledPlans: disabled, SKUID: l.SKUID}
+}
+
+// containsPlan reports whether the service plan id is in the list. Ids are guids, so they're
+// compared case insensitively.
+func containsPlan(ids []string, id string) bool {
+	for _, i := range ids {
+		if strings.EqualFold(i, id) {
+			return true
+		}
+	}
+	return false
+}
+
+// LicenseDetails describes a license assigned to a user, along with the service plans it includes
+// and whether each of them is provisioned for the user.
+type LicenseDetails struct {
+	ID            string                   `json:"id"`
+	ServicePlans  []common.ServicePlanInfo `json:"servicePlans"`
+	SKUID         string                   `json:"skuId"`
+	SKUPartNumber string                   `json:"skuPartNumber"`
+}
+
+// assignLicenseRequest is the request body of the assignLicense action. Both lists are required by
+// the Graph API, even when empty.
+type assignLicenseRequest struct {
+	AddLicenses    []AssignedLicense `json:"addLicenses"`
+	RemoveLicenses []string          `json:"removeLicenses"`
+}
+
+// AssignLicense adds and removes licenses of a user, by id or principal name, returning the updated
+// user. Licenses are removed by their SKU id. Adding a license the user already has replaces its
+// disabled plans, which is how individual service plans are switched on or off; see
+// AssignedLicense.WithPlansDisabled. The user needs a UsageLocation before licenses can be
+// assigned to them.
+func (s *ServiceContext) AssignLicense(userIDOrPrincipal string, addLicenses []AssignedLicense, removeLicenses []string) (User, error) {
+	return s.AssignLicenseContext(context.Background(), userIDOrPrincipal, addLicenses, removeLicenses)
+}
+
+// AssignLicenseContext is AssignLicense, bound to the given context.
+func (s *ServiceContext) AssignLicenseContext(ctx context.Context, userIDOrPrincipal string, addLicenses []AssignedLicense, removeLicenses []string) (User, error) {
+	if err := s.preflight(ctx, "AssignLicense", assignLicensePermissions); err != nil {
+		return User{}, err
+	}
+	body := assignLicenseRequest{
+		AddLicenses:    make([]AssignedLicense, len(addLicenses)),
+		RemoveLicenses: append([]string{}, removeLicenses...),
+	}
+	for i, l := range addLicenses {
+		if l.DisabledPlans == nil {
+			l.DisabledPlans = []string{}
+		}
+		body.AddLicenses[i] = l
+	}
+	reqURL := fmt.Sprintf("v1.0/users/%v/assignLicense", userIDOrPrincipal)
+	b, err := internal.GraphRequestContext(ctx, s.client, "POST", reqURL, nil, body)
+	if err != nil {
+		return User{}, err
+	}
+	var data GetUserResponse
+	err = json.Unmarshal(b, &data)
+	if err != nil {
+		return User{}, err
+	}
+	return data.User, nil
+}
+
+// ListLicenseDetails returns the licenses assigned to a user, by id or principal name, with the
+// service plans of each.
+func (s *ServiceContext) ListLicenseDetails(userIDOrPrincipal string) ([]LicenseDetails, error) {
+	return s.ListLicenseDetailsContext(context.Background(), userIDOrPrincipal)
+}
+
+// ListLicenseDetailsContext is ListLicenseDetails, bound to the given context.
+func (s *ServiceContext) ListLicenseDetailsContext(ctx context.Context, userIDOrPrincipal string) ([]LicenseDetails, error) {
+	if err := s.preflight(ctx, "ListLicenseDetails", readDirectoryPermissions); err != nil {
+		return nil, err
+	}
+	reqURL := internal.GraphURL(s.client, fmt.Sprintf("v1.0/users/%v/licenseDetails", userIDOrPrincipal), nil)
+	pages := internal.NewPageIterator(ctx, s.client, reqURL)
+	var details []LicenseDetails
+	for pages.Next() {
+		var d LicenseDetails
+		if err := json.Unmarshal(pages.Value(), &d); err != nil {
+			return nil, err
+		}
+		details = append(details, d)
+	}
+	if err := pages.Err(); err != nil {
+		return nil, err
+	}
+	return details, nil
+}
@@ -0,0 +1,62 @@
+package users
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+func TestAssignLicenseSendsEmptyLists(t *testing.T) {
+	var body string
+	s := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/v1.0/users/u/assignLicense" {
+			t.Errorf("unexpected request %v %v", r.Method, r.URL.Path)
+		}
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body = string(b)
+		w.Write([]byte(`{"id":"u"}`))
+	})
+	cases := []struct {
+		add      []AssignedLicense
+		remove   []string
+		expected string
+	}{
+		{
+			add:      []AssignedLicense{{SKUID: "sku"}},
+			expected: `{"addLicenses":[{"disabledPlans":[],"skuId":"sku"}],"removeLicenses":[]}`,
+		},
+		{
+			remove:   []string{"sku"},
+			expected: `{"addLicenses":[],"removeLicenses":["sku"]}`,
+		},
+	}
+	for _, c := range cases {
+		if _, err := s.AssignLicense("u", c.add, c.remove); err != nil {
+			t.Fatal(err)
+		}
+		if body != c.expected {
+			t.Fatalf("expected the body %v, got %v", c.expected, body)
+		}
+	}
+}
+
+func TestAssignedLicensePlans(t *testing.T) {
+	license := AssignedLicense{SKUID: "sku", DisabledPlans: []string{"efb87545-963c-4e0d-99df-69c6916d9eb0"}}
+	disabled := license.WithPlansDisabled("EFB87545-963C-4E0D-99DF-69C6916D9EB0", "5dbe027f-2339-4123-9542-606e4d348a72")
+	if len(disabled.DisabledPlans) != 2 || disabled.DisabledPlans[1] != "5dbe027f-2339-4123-9542-606e4d348a72" {
+		t.Fatalf("expected an already disabled plan not to be added again, got %v", disabled.DisabledPlans)
+	}
+	if len(license.DisabledPlans) != 1 {
+		t.Fatalf("expected the original license to be unchanged, got %v", license.DisabledPlans)
+	}
+	enabled := disabled.WithPlansEnabled("EFB87545-963C-4E0D-99DF-69C6916D9EB0")
+	if len(enabled.DisabledPlans) != 1 || enabled.DisabledPlans[0] != "5dbe027f-2339-4123-9542-606e4d348a72" {
+		t.Fatalf("expected the plan to be enabled regardless of case, got %v", enabled.DisabledPlans)
+	}
+	if all := enabled.WithPlansEnabled("5dbe027f-2339-4123-9542-606e4d348a72"); all.DisabledPlans == nil || len(all.DisabledPlans) != 0 {
+		t.Fatalf("expected an empty list of disabled plans, got %#v", all.DisabledPlans)
+	}
+}
@@ -1,20 +1,161 @@
 package users
 
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mhoc/msgoraph/common"
+	"github.com/mhoc/msgoraph/internal"
+)
+
+// The values of AutomaticRepliesSetting.Status.
+const (
+	AutomaticRepliesDisabled      = "disabled"
+	AutomaticRepliesAlwaysEnabled = "alwaysEnabled"
+	AutomaticRepliesScheduled     = "scheduled"
+)
+
+// The values of AutomaticRepliesSetting.ExternalAudience.
+const (
+	ExternalAudienceNone         = "none"
+	ExternalAudienceContactsOnly = "contactsOnly"
+	ExternalAudienceAll          = "all"
+)
+
 // AutomaticRepliesSetting configuration settings to automatically notify the sender of an
 // incoming email with a message from the signed-in user. For example, an automatic reply to
 // notify that the signed-in user is unavailable to respond to emails.
 type AutomaticRepliesSetting struct {
-	ExternalAudience       string `json:"externalAudience"`
-	ExternalReplyMessage   string `json:"externalReplyMessage"`
-	InternalReplyMessage   string `json:"InternalReplyMessage"`
-	ScheduledEndDateTime   string `json:"scheduledEndDateTime"`
-	ScheduledStartDateTime string `json:"scheduledStartDateTime"`
-	Status                 string `json:"status"`
+	ExternalAudience       string                   `json:"externalAudience,omitempty"`
+	ExternalReplyMessage   string                   `json:"externalReplyMessage,omitempty"`
+	InternalReplyMessage   string                   `json:"internalReplyMessage,omitempty"`
+	ScheduledEndDateTime   *common.DateTimeTimeZone `json:"scheduledEndDateTime,omitempty"`
+	ScheduledStartDateTime *common.DateTimeTimeZone `json:"scheduledStartDateTime,omitempty"`
+	Status                 string                   `json:"status,omitempty"`
 }
 
-// MailboxSettings Settings for the primary mailbox of the signed-in user.
+// ScheduledAutomaticReplies returns the automatic replies setting of an out-of-office window from
+// start to end. The external message is sent to all external senders, unless it's empty, in which
+// case only internal senders get a reply.
+func ScheduledAutomaticReplies(start time.Time, end time.Time, internalReplyMessage string, externalReplyMessage string) AutomaticRepliesSetting {
+	startAt := common.NewDateTimeTimeZone(start)
+	endAt := common.NewDateTimeTimeZone(end)
+	audience := ExternalAudienceAll
+	if externalReplyMessage == "" {
+		audience = ExternalAudienceNone
+	}
+	return AutomaticRepliesSetting{
+		ExternalAudience:       audience,
+		ExternalReplyMessage:   externalReplyMessage,
+		InternalReplyMessage:   internalReplyMessage,
+		ScheduledEndDateTime:   &endAt,
+		ScheduledStartDateTime: &startAt,
+		Status:                 AutomaticRepliesScheduled,
+	}
+}
+
+// TimeZoneBase names a time zone, either a Windows time zone name such as "Pacific Standard Time"
+// or an IANA name such as "America/Los_Angeles".
+type TimeZoneBase struct {
+	Name string `json:"name"`
+}
+
+// WorkingHours are the days of the week and hours of the day a user works. StartTime and EndTime
+// are times of day such as "08:00:00.0000000", in TimeZone.
+type WorkingHours struct {
+	DaysOfWeek []string      `json:"daysOfWeek,omitempty"`
+	EndTime    string        `json:"endTime,omitempty"`
+	StartTime  string        `json:"startTime,omitempty"`
+	TimeZone   *TimeZoneBase `json:"timeZone,omitempty"`
+}
+
+// MailboxSettings Settings for the primary mailbox of the signed-in user. When updating them, only
+// the settings which are set are changed.
 type MailboxSettings struct {
-	AutomaticRepliesSetting AutomaticRepliesSetting `json:"automaticRepliesSetting"`
-	Language                LocaleInfo              `json:"localeInfo"`
-	TimeZone                string                  `json:"timeZone"`
+	AutomaticRepliesSetting               *AutomaticRepliesSetting `json:"automaticRepliesSetting,omitempty"`
+	DateFormat                            string                   `json:"dateFormat,omitempty"`
+	DelegateMeetingMessageDeliveryOptions string                   `json:"delegateMeetingMessageDeliveryOptions,omitempty"`
+	Language                              *LocaleInfo              `json:"language,omitempty"`
+	TimeFormat                            string                   `json:"timeFormat,omitempty"`
+	TimeZone                              string                   `json:"timeZone,omitempty"`
+	WorkingHours                          *WorkingHours            `json:"workingHours,omitempty"`
+}
+
+// GetMailboxSettings returns the mailbox settings of a user, by id or principal name.
+func (s *ServiceContext) GetMailboxSettings(userIDOrPrincipal string) (MailboxSettings, error) {
+	return s.GetMailboxSettingsContext(context.Background(), userIDOrPrincipal)
+}
+
+// GetMailboxSettingsContext is GetMailboxSettings, bound to the given context.
+func (s *ServiceContext) GetMailboxSettingsContext(ctx context.Context, userIDOrPrincipal string) (MailboxSettings, error) {
+	if err := s.preflight(ctx, "GetMailboxSettings", readMailboxSettingsPermissions); err != nil {
+		return MailboxSettings{}, err
+	}
+	reqURL := fmt.Sprintf("v1.0/users/%v/mailboxSettings", userIDOrPrincipal)
+	b, err := internal.GraphRequestContext(ctx, s.client, "GET", reqURL, nil, nil)
+	if err != nil {
+		return MailboxSettings{}, err
+	}
+	var settings MailboxSettings
+	err = json.Unmarshal(b, &settings)
+	if err != nil {
+		return MailboxSettings{}, err
+	}
+	return settings, nil
+}
+
+// UpdateMailboxSettings changes the mailbox settings of a user, by id or principal name, returning
+// the settings which were changed. Only the settings which are set are sent.
+func (s *ServiceContext) UpdateMailboxSettings(userIDOrPrincipal string, settings MailboxSettings) (MailboxSettings, error) {
+	return s.UpdateMailboxSettingsContext(context.Background(), userIDOrPrincipal, settings)
+}
+
+// UpdateMailboxSettingsContext is UpdateMailboxSettings, bound to the given context.
+func (s *ServiceContext) UpdateMailboxSettingsContext(ctx context.Context, userIDOrPrincipal string, settings MailboxSettings) (MailboxSettings, error) {
+	if err := s.preflight(ctx, "UpdateMailboxSettings", writeMailboxSettingsPermissions); err != nil {
+		return MailboxSettings{}, err
+	}
+	reqURL := fmt.Sprintf("v1.0/users/%v/mailboxSettings", userIDOrPrincipal)
+	b, err := internal.GraphRequestContext(ctx, s.client, "PATCH", reqURL, nil, settings)
+	if err != nil {
+		return MailboxSettings{}, err
+	}
+	var updated MailboxSettings
+	err = json.Unmarshal(b, &updated)
+	if err != nil {
+		return MailboxSettings{}, err
+	}
+	return updated, nil
+}
+
+// SetOutOfOffice schedules automatic replies for a user, by id or principal name, from start to
+// end. See ScheduledAutomaticReplies for who gets which message.
+func (s *ServiceContext) SetOutOfOffice(userIDOrPrincipal string, start time.Time, end time.Time, internalReplyMessage string, externalReplyMessage string) error {
+	return s.SetOutOfOfficeContext(context.Background(), userIDOrPrincipal, start, end, internalReplyMessage, externalReplyMessage)
+}
+
+// SetOutOfOfficeContext is SetOutOfOffice, bound to the given context.
+func (s *ServiceContext) SetOutOfOfficeContext(ctx context.Context, userIDOrPrincipal string, start time.Time, end time.Time, internalReplyMessage string, externalReplyMessage string) error {
+	if !end.After(start) {
+		return errors.New("out of office window must end after it starts")
+	}
+	replies := ScheduledAutomaticReplies(start, end, internalReplyMessage, externalReplyMessage)
+	_, err := s.UpdateMailboxSettingsContext(ctx, userIDOrPrincipal, MailboxSettings{AutomaticRepliesSetting: &replies})
+	return err
+}
+
+// DisableAutomaticReplies turns off the automatic replies of a user, by id or principal name,
+// whether they were scheduled or always on.
+func (s *ServiceContext) DisableAutomaticReplies(userIDOrPrincipal string) error {
+	return s.DisableAutomaticRepliesContext(context.Background(), userIDOrPrincipal)
+}
+
+// DisableAutomaticRepliesContext is DisableAutomaticReplies, bound to the given context.
+func (s *ServiceContext) DisableAutomaticRepliesContext(ctx context.Context, userIDOrPrincipal string) error {
+	replies := AutomaticRepliesSetting{Status: AutomaticRepliesDisabled}
+	_, err := s.UpdateMailboxSettingsContext(ctx, userIDOrPrincipal, MailboxSettings{AutomaticRepliesSetting: &replies})
+	return err
 }
@@ -0,0 +1,114 @@
+package users
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// graphMailboxSettings is a mailboxSettings payload as returned by the Graph API.
+const graphMailboxSettings = `{
+	"automaticRepliesSetting": {
+		"status": "scheduled",
+		"externalAudience": "all",
+		"internalReplyMessage": "<p>Away until Monday</p>",
+		"externalReplyMessage": "<p>Away</p>",
+		"scheduledStartDateTime": {"dateTime": "2026-01-02T08:00:00.0000000", "timeZone": "UTC"},
+		"scheduledEndDateTime": {"dateTime": "2026-01-05T08:00:00.1234567", "timeZone": "UTC"}
+	},
+	"dateFormat": "MM/dd/yyyy",
+	"delegateMeetingMessageDeliveryOptions": "sendToDelegateOnly",
+	"language": {"locale": "en-US", "displayName": "English (United States)"},
+	"timeFormat": "hh:mm tt",
+	"timeZone": "Pacific Standard Time",
+	"workingHours": {
+		"daysOfWeek": ["monday", "tuesday", "wednesday", "thursday", "friday"],
+		"startTime": "08:00:00.0000000",
+		"endTime": "17:00:00.0000000",
+		"timeZone": {"name": "Pacific Standard Time"}
+	}
+}`
+
+func TestMailboxSettingsRoundTrip(t *testing.T) {
+	var settings MailboxSettings
+	if err := json.Unmarshal([]byte(graphMailboxSettings), &settings); err != nil {
+		t.Fatal(err)
+	}
+	if settings.Language == nil || settings.Language.Locale != "en-US" {
+		t.Fatalf("expected the language to be decoded, got %+v", settings.Language)
+	}
+	replies := settings.AutomaticRepliesSetting
+	if replies == nil || replies.InternalReplyMessage != "<p>Away until Monday</p>" {
+		t.Fatalf("expected the internal reply message to be decoded, got %+v", replies)
+	}
+	start, err := replies.ScheduledStartDateTime.Time()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !start.Equal(time.Date(2026, 1, 2, 8, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected start %v", start)
+	}
+	end, err := replies.ScheduledEndDateTime.Time()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !end.Equal(time.Date(2026, 1, 5, 8, 0, 0, 123456700, time.UTC)) {
+		t.Fatalf("unexpected end %v", end)
+	}
+	b, err := json.Marshal(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var expected, got interface{}
+	json.Unmarshal([]byte(graphMailboxSettings), &expected)
+	json.Unmarshal(b, &got)
+	if !reflect.DeepEqual(expected, got) {
+		t.Fatalf("expected the settings to encode as they were returned, got %s", b)
+	}
+}
+
+func TestSetOutOfOffice(t *testing.T) {
+	var body map[string]interface{}
+	s := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PATCH" || r.URL.Path != "/v1.0/users/u/mailboxSettings" {
+			t.Errorf("unexpected request %v %v", r.Method, r.URL.Path)
+		}
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(b, &body); err != nil {
+			t.Fatal(err)
+		}
+		w.Write(b)
+	})
+	start := time.Date(2026, 1, 2, 9, 0, 0, 0, time.FixedZone("CET", 3600))
+	end := start.Add(72 * time.Hour)
+	if err := s.SetOutOfOffice("u", start, end, "Away", ""); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"automaticRepliesSetting": map[string]interface{}{
+			"externalAudience":       "none",
+			"internalReplyMessage":   "Away",
+			"scheduledStartDateTime": map[string]interface{}{"dateTime": "2026-01-02T08:00:00.0000000", "timeZone": "UTC"},
+			"scheduledEndDateTime":   map[string]interface{}{"dateTime": "2026-01-05T08:00:00.0000000", "timeZone": "UTC"},
+			"status":                 "scheduled",
+		},
+	}
+	if !reflect.DeepEqual(body, expected) {
+		t.Fatalf("expected the body %v, got %v", expected, body)
+	}
+	body = nil
+	for _, end := range []time.Time{start, start.Add(-time.Hour)} {
+		if err := s.SetOutOfOffice("u", start, end, "Away", ""); err == nil {
+			t.Fatalf("expected a window ending at %v to be rejected", end)
+		}
+	}
+	if body != nil {
+		t.Fatalf("expected a rejected window not to be sent, got %v", body)
+	}
+}
@@ -0,0 +1,254 @@
+package users
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mhoc/msgoraph/common"
+	"github.com/mhoc/msgoraph/internal"
+)
+
+// DirectoryObjectIterator lazily walks a collection of directory objects, such as the groups a user
+// is a member of, only requesting each page from the Graph API once the objects before it have been
+// consumed.
+type DirectoryObjectIterator struct {
+	err    error
+	object common.DirectoryObject
+	pages  *internal.PageIterator
+}
+
+// Next advances to the next object, returning false when there are none left or an error occurred.
+func (it *DirectoryObjectIterator) Next() bool {
+	if it.err != nil || !it.pages.Next() {
+		return false
+	}
+	it.object = common.DirectoryObject{}
+	if err := json.Unmarshal(it.pages.Value(), &it.object); err != nil {
+		it.err = err
+		return false
+	}
+	return true
+}
+
+// Object returns the current directory object.
+func (it *DirectoryObjectIterator) Object() common.DirectoryObject {
+	return it.object
+}
+
+// Err returns the error which stopped iteration, if any.
+func (it *DirectoryObjectIterator) Err() error {
+	if it.err != nil {
+		return it.err
+	}
+	return it.pages.Err()
+}
+
+// iterateDirectoryObjects returns an iterator over a navigation property of a user which returns
+// directory objects. Every page request is bound to the given context, so cancelling it stops
+// pagination at the next page; the List variants of each property collect such an iterator.
+func (s *ServiceContext) iterateDirectoryObjects(ctx context.Context, operation string, permissions []string, userIDOrPrincipal string, navigation string) *DirectoryObjectIterator {
+	if err := s.preflight(ctx, operation, permissions); err != nil {
+		return &DirectoryObjectIterator{err: err, pages: internal.NewPageIterator(ctx, s.client, "")}
+	}
+	reqURL := internal.GraphURL(s.client, fmt.Sprintf("v1.0/users/%v/%v", userIDOrPrincipal, navigation), nil)
+	return &DirectoryObjectIterator{pages: internal.NewPageIterator(ctx, s.client, reqURL)}
+}
+
+// collectDirectoryObjects reads every object of an iterator.
+func collectDirectoryObjects(it *DirectoryObjectIterator) ([]common.DirectoryObject, error) {
+	var objects []common.DirectoryObject
+	for it.Next() {
+		objects = append(objects, it.Object())
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+// GetManager returns the manager of a user, by id or principal name. The manager is usually a user,
+// but may be an organizational contact. If the user has no manager, the error satisfies
+// client.IsNotFound.
+func (s *ServiceContext) GetManager(userIDOrPrincipal string) (common.DirectoryObject, error) {
+	return s.GetManagerContext(context.Background(), userIDOrPrincipal)
+}
+
+// GetManagerContext is GetManager, bound to the given context.
+func (s *ServiceContext) GetManagerContext(ctx context.Context, userIDOrPrincipal string) (common.DirectoryObject, error) {
+	if err := s.preflight(ctx, "GetManager", readDirectoryPermissions); err != nil {
+		return common.DirectoryObject{}, err
+	}
+	reqURL := fmt.Sprintf("v1.0/users/%v/manager", userIDOrPrincipal)
+	b, err := internal.GraphRequestContext(ctx, s.client, "GET", reqURL, nil, nil)
+	if err != nil {
+		return common.DirectoryObject{}, err
+	}
+	var manager common.DirectoryObject
+	err = json.Unmarshal(b, &manager)
+	if err != nil {
+		return common.DirectoryObject{}, err
+	}
+	return manager, nil
+}
+
+// SetManager makes the user with the id managerID the manager of a user, by id or principal name.
+func (s *ServiceContext) SetManager(userIDOrPrincipal string, managerID string) error {
+	return s.SetManagerContext(context.Background(), userIDOrPrincipal, managerID)
+}
+
+// SetManagerContext is SetManager, bound to the given context.
+func (s *ServiceContext) SetManagerContext(ctx context.Context, userIDOrPrincipal string, managerID string) error {
+	if err := s.preflight(ctx, "SetManager", writeDirectoryPermissions); err != nil {
+		return err
+	}
+	reqURL := fmt.Sprintf("v1.0/users/%v/manager/$ref", userIDOrPrincipal)
+	ref := map[string]string{
+		"@odata.id": internal.GraphURL(s.client, fmt.Sprintf("v1.0/users/%v", managerID), nil),
+	}
+	_, err := internal.GraphRequestContext(ctx, s.client, "PUT", reqURL, nil, ref)
+	return err
+}
+
+// RemoveManager removes the manager of a user, by id or principal name.
+func (s *ServiceContext) RemoveManager(userIDOrPrincipal string) error {
+	return s.RemoveManagerContext(context.Background(), userIDOrPrincipal)
+}
+
+// RemoveManagerContext is RemoveManager, bound to the given context.
+func (s *ServiceContext) RemoveManagerContext(ctx context.Context, userIDOrPrincipal string) error {
+	if err := s.preflight(ctx, "RemoveManager", writeDirectoryPermissions); err != nil {
+		return err
+	}
+	reqURL := fmt.Sprintf("v1.0/users/%v/manager/$ref", userIDOrPrincipal)
+	_, err := internal.GraphRequestContext(ctx, s.client, "DELETE", reqURL, nil, nil)
+	return err
+}
+
+// ListDirectReports returns the users and contacts who report to a user, by id or principal name.
+func (s *ServiceContext) ListDirectReports(userIDOrPrincipal string) ([]common.DirectoryObject, error) {
+	return s.ListDirectReportsContext(context.Background(), userIDOrPrincipal)
+}
+
+// ListDirectReportsContext is ListDirectReports, bound to the given context.
+func (s *ServiceContext) ListDirectReportsContext(ctx context.Context, userIDOrPrincipal string) ([]common.DirectoryObject, error) {
+	return collectDirectoryObjects(s.IterateDirectReportsContext(ctx, userIDOrPrincipal))
+}
+
+// IterateDirectReports is ListDirectReports, returning an iterator which requests pages as they
+// are consumed.
+func (s *ServiceContext) IterateDirectReports(userIDOrPrincipal string) *DirectoryObjectIterator {
+	return s.IterateDirectReportsContext(context.Background(), userIDOrPrincipal)
+}
+
+// IterateDirectReportsContext is IterateDirectReports, bound to the given context.
+func (s *ServiceContext) IterateDirectReportsContext(ctx context.Context, userIDOrPrincipal string) *DirectoryObjectIterator {
+	return s.iterateDirectoryObjects(ctx, "ListDirectReports", readDirectoryPermissions, userIDOrPrincipal, "directReports")
+}
+
+// ListMemberOf returns the groups, directory roles and administrative units a user, by id or
+// principal name, is a direct member of.
+func (s *ServiceContext) ListMemberOf(userIDOrPrincipal string) ([]common.DirectoryObject, error) {
+	return s.ListMemberOfContext(context.Background(), userIDOrPrincipal)
+}
+
+// ListMemberOfContext is ListMemberOf, bound to the given context.
+func (s *ServiceContext) ListMemberOfContext(ctx context.Context, userIDOrPrincipal string) ([]common.DirectoryObject, error) {
+	return collectDirectoryObjects(s.IterateMemberOfContext(ctx, userIDOrPrincipal))
+}
+
+// IterateMemberOf is ListMemberOf, returning an iterator which requests pages as they are consumed.
+func (s *ServiceContext) IterateMemberOf(userIDOrPrincipal string) *DirectoryObjectIterator {
+	return s.IterateMemberOfContext(context.Background(), userIDOrPrincipal)
+}
+
+// IterateMemberOfContext is IterateMemberOf, bound to the given context.
+func (s *ServiceContext) IterateMemberOfContext(ctx context.Context, userIDOrPrincipal string) *DirectoryObjectIterator {
+	return s.iterateDirectoryObjects(ctx, "ListMemberOf", memberOfPermissions, userIDOrPrincipal, "memberOf")
+}
+
+// ListTransitiveMemberOf returns the groups, directory roles and administrative units a user, by
+// id or principal name, is a member of, either directly or through nested groups.
+func (s *ServiceContext) ListTransitiveMemberOf(userIDOrPrincipal string) ([]common.DirectoryObject, error) {
+	return s.ListTransitiveMemberOfContext(context.Background(), userIDOrPrincipal)
+}
+
+// ListTransitiveMemberOfContext is ListTransitiveMemberOf, bound to the given context.
+func (s *ServiceContext) ListTransitiveMemberOfContext(ctx context.Context, userIDOrPrincipal string) ([]common.DirectoryObject, error) {
+	return collectDirectoryObjects(s.IterateTransitiveMemberOfContext(ctx, userIDOrPrincipal))
+}
+
+// IterateTransitiveMemberOf is ListTransitiveMemberOf, returning an iterator which requests pages
+// as they are consumed.
+func (s *ServiceContext) IterateTransitiveMemberOf(userIDOrPrincipal string) *DirectoryObjectIterator {
+	return s.IterateTransitiveMemberOfContext(context.Background(), userIDOrPrincipal)
+}
+
+// IterateTransitiveMemberOfContext is IterateTransitiveMemberOf, bound to the given context.
+func (s *ServiceContext) IterateTransitiveMemberOfContext(ctx context.Context, userIDOrPrincipal string) *DirectoryObjectIterator {
+	return s.iterateDirectoryObjects(ctx, "ListTransitiveMemberOf", memberOfPermissions, userIDOrPrincipal, "transitiveMemberOf")
+}
+
+// ListOwnedObjects returns the directory objects, such as groups and applications, owned by a
+// user, by id or principal name.
+func (s *ServiceContext) ListOwnedObjects(userIDOrPrincipal string) ([]common.DirectoryObject, error) {
+	return s.ListOwnedObjectsContext(context.Background(), userIDOrPrincipal)
+}
+
+// ListOwnedObjectsContext is ListOwnedObjects, bound to the given context.
+func (s *ServiceContext) ListOwnedObjectsContext(ctx context.Context, userIDOrPrincipal string) ([]common.DirectoryObject, error) {
+	return collectDirectoryObjects(s.IterateOwnedObjectsContext(ctx, userIDOrPrincipal))
+}
+
+// IterateOwnedObjects is ListOwnedObjects, returning an iterator which requests pages as they
+// are consumed.
+func (s *ServiceContext) IterateOwnedObjects(userIDOrPrincipal string) *DirectoryObjectIterator {
+	return s.IterateOwnedObjectsContext(context.Background(), userIDOrPrincipal)
+}
+
+// IterateOwnedObjectsContext is IterateOwnedObjects, bound to the given context.
+func (s *ServiceContext) IterateOwnedObjectsContext(ctx context.Context, userIDOrPrincipal string) *DirectoryObjectIterator {
+	return s.iterateDirectoryObjects(ctx, "ListOwnedObjects", readDirectoryPermissions, userIDOrPrincipal, "ownedObjects")
+}
+
+// ListCreatedObjects returns the directory objects created by a user, by id or principal name.
+func (s *ServiceContext) ListCreatedObjects(userIDOrPrincipal string) ([]common.DirectoryObject, error) {
+	return s.ListCreatedObjectsContext(context.Background(), userIDOrPrincipal)
+}
+
+// ListCreatedObjectsContext is ListCreatedObjects, bound to the given context.
+func (s *ServiceContext) ListCreatedObjectsContext(ctx context.Context, userIDOrPrincipal string) ([]common.DirectoryObject, error) {
+	return collectDirectoryObjects(s.IterateCreatedObjectsContext(ctx, userIDOrPrincipal))
+}
+
+// IterateCreatedObjects is ListCreatedObjects, returning an iterator which requests pages as they
+// are consumed.
+func (s *ServiceContext) IterateCreatedObjects(userIDOrPrincipal string) *DirectoryObjectIterator {
+	return s.IterateCreatedObjectsContext(context.Background(), userIDOrPrincipal)
+}
+
+// IterateCreatedObjectsContext is IterateCreatedObjects, bound to the given context.
+func (s *ServiceContext) IterateCreatedObjectsContext(ctx context.Context, userIDOrPrincipal string) *DirectoryObjectIterator {
+	return s.iterateDirectoryObjects(ctx, "ListCreatedObjects", readDirectoryPermissions, userIDOrPrincipal, "createdObjects")
+}
+
+// ListOwnedDevices returns the devices owned by a user, by id or principal name.
+func (s *ServiceContext) ListOwnedDevices(userIDOrPrincipal string) ([]common.DirectoryObject, error) {
+	return s.ListOwnedDevicesContext(context.Background(), userIDOrPrincipal)
+}
+
+// ListOwnedDevicesContext is ListOwnedDevices, bound to the given context.
+func (s *ServiceContext) ListOwnedDevicesContext(ctx context.Context, userIDOrPrincipal string) ([]common.DirectoryObject, error) {
+	return collectDirectoryObjects(s.IterateOwnedDevicesContext(ctx, userIDOrPrincipal))
+}
+
+// IterateOwnedDevices is ListOwnedDevices, returning an iterator which requests pages as they
+// are consumed.
+func (s *ServiceContext) IterateOwnedDevices(userIDOrPrincipal string) *DirectoryObjectIterator {
+	return s.IterateOwnedDevicesContext(context.Background(), userIDOrPrincipal)
+}
+
+// IterateOwnedDevicesContext is IterateOwnedDevices, bound to the given context.
+func (s *ServiceContext) IterateOwnedDevicesContext(ctx context.Context, userIDOrPrincipal string) *DirectoryObjectIterator {
+	return s.iterateDirectoryObjects(ctx, "ListOwnedDevices", readDirectoryPermissions, userIDOrPrincipal, "ownedDevices")
+}
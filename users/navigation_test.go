@@ -0,0 +1,92 @@
+package users
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/mhoc/msgoraph/common"
+)
+
+func TestGetManagerDecodesDirectoryObject(t *testing.T) {
+	s := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" || r.URL.Path != "/v1.0/users/u/manager" {
+			t.Errorf("unexpected request %v %v", r.Method, r.URL.Path)
+		}
+		w.Write([]byte(`{"@odata.type":"#microsoft.graph.user","id":"m","displayName":"Megan","jobTitle":"Director"}`))
+	})
+	manager, err := s.GetManager("u")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manager.ID != "m" || manager.DisplayName != "Megan" || !manager.IsType(common.ODataTypeUser) {
+		t.Fatalf("unexpected manager %+v", manager)
+	}
+	var user User
+	if err := manager.Decode(&user); err != nil {
+		t.Fatal(err)
+	}
+	if user.JobTitle == nil || *user.JobTitle != "Director" {
+		t.Fatalf("expected the full user to be decoded from Raw, got %+v", user)
+	}
+}
+
+func TestSetAndRemoveManager(t *testing.T) {
+	var requests []string
+	s := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		if r.Method == "PUT" {
+			b, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ref map[string]string
+			if err := json.Unmarshal(b, &ref); err != nil {
+				t.Fatal(err)
+			}
+			expected := fmt.Sprintf("http://%v/v1.0/users/m", r.Host)
+			if len(ref) != 1 || ref["@odata.id"] != expected {
+				t.Errorf("expected a reference to %v, got %s", expected, b)
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	if err := s.SetManager("u", "m"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RemoveManager("u"); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"PUT /v1.0/users/u/manager/$ref", "DELETE /v1.0/users/u/manager/$ref"}
+	if len(requests) != len(expected) || requests[0] != expected[0] || requests[1] != expected[1] {
+		t.Fatalf("expected requests %v, got %v", expected, requests)
+	}
+}
+
+func TestListMemberOfDecodesEachKind(t *testing.T) {
+	s := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("$skiptoken") == "" {
+			fmt.Fprintf(w, `{"value":[{"@odata.type":"#microsoft.graph.group","id":"g","displayName":"Sales"}],"@odata.nextLink":"http://%v/v1.0/users/u/memberOf?$skiptoken=2"}`, r.Host)
+			return
+		}
+		w.Write([]byte(`{"value":[{"@odata.type":"#microsoft.graph.directoryRole","id":"r","displayName":"Global Reader","roleTemplateId":"t"}]}`))
+	})
+	objects, err := s.ListMemberOf("u")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 2 || !objects[0].IsType(common.ODataTypeGroup) || !objects[1].IsType(common.ODataTypeDirectoryRole) {
+		t.Fatalf("expected a group and a directory role across both pages, got %+v", objects)
+	}
+	var role struct {
+		RoleTemplateID string `json:"roleTemplateId"`
+	}
+	if err := objects[1].Decode(&role); err != nil {
+		t.Fatal(err)
+	}
+	if objects[1].ID != "r" || role.RoleTemplateID != "t" {
+		t.Fatalf("unexpected directory role %+v, %+v", objects[1], role)
+	}
+}
@@ -0,0 +1,37 @@
+package users
+
+import (
+	"context"
+
+	"github.com/mhoc/msgoraph/client"
+)
+
+// The permissions each operation accepts, least privileged first, as checked by the permission
+// preflight when client.Config.PermissionPreflight is set. See the permissions section of each
+// operation at https://docs.microsoft.com/en-us/graph/api/resources/user.
+var (
+	createUserPermissions = []string{"User.ReadWrite.All", "Directory.ReadWrite.All"}
+	deleteUserPermissions = []string{"User.ReadWrite.All", "Directory.AccessAsUser.All"}
+	getUserPermissions    = []string{"User.Read", "User.ReadBasic.All", "User.Read.All", "User.ReadWrite.All", "Directory.Read.All", "Directory.ReadWrite.All", "Directory.AccessAsUser.All"}
+	listUsersPermissions  = []string{"User.ReadBasic.All", "User.Read.All", "User.ReadWrite.All", "Directory.Read.All", "Directory.ReadWrite.All", "Directory.AccessAsUser.All"}
+	updateUserPermissions = []string{"User.ReadWrite", "User.ReadWrite.All", "User.ManageIdentities.All", "Directory.ReadWrite.All", "Directory.AccessAsUser.All"}
+	userDeltaPermissions  = []string{"User.Read.All", "User.ReadWrite.All", "Directory.Read.All", "Directory.ReadWrite.All"}
+
+	memberOfPermissions       = []string{"GroupMember.Read.All", "User.Read.All", "User.ReadWrite.All", "Directory.Read.All", "Directory.ReadWrite.All", "Directory.AccessAsUser.All"}
+	readDirectoryPermissions  = []string{"User.Read.All", "User.ReadWrite.All", "Directory.Read.All", "Directory.ReadWrite.All", "Directory.AccessAsUser.All"}
+	writeDirectoryPermissions = []string{"User.ReadWrite.All", "Directory.ReadWrite.All", "Directory.AccessAsUser.All"}
+
+	assignLicensePermissions = []string{"LicenseAssignment.ReadWrite.All", "User.ReadWrite.All", "Directory.ReadWrite.All"}
+
+	readMailboxSettingsPermissions  = []string{"MailboxSettings.Read", "MailboxSettings.ReadWrite"}
+	writeMailboxSettingsPermissions = []string{"MailboxSettings.ReadWrite"}
+
+	readPhotoPermissions  = []string{"User.Read", "User.ReadBasic.All", "User.Read.All", "User.ReadWrite.All", "ProfilePhoto.Read.All", "ProfilePhoto.ReadWrite.All", "Directory.Read.All", "Directory.ReadWrite.All"}
+	writePhotoPermissions = []string{"User.ReadWrite", "User.ReadWrite.All", "ProfilePhoto.ReadWrite.All", "Directory.ReadWrite.All"}
+)
+
+// preflight checks that the client's access token grants one of the permissions the operation
+// accepts, if the client is configured to check.
+func (s *ServiceContext) preflight(ctx context.Context, operation string, permissions []string) error {
+	return client.CheckPermissions(ctx, s.client, "users."+operation, permissions...)
+}
@@ -0,0 +1,163 @@
+package users
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/mhoc/msgoraph/client"
+	"github.com/mhoc/msgoraph/internal"
+)
+
+// ErrNoPhoto is matched by errors.Is when a user exists but has no profile photo, or none of the
+// requested size. The error also satisfies client.IsNotFound.
+var ErrNoPhoto = errors.New("user has no profile photo")
+
+// PhotoMetadata describes one size of a user's profile photo. See
+// https://docs.microsoft.com/en-us/graph/api/resources/profilephoto.
+type PhotoMetadata struct {
+	// ID is the size of the photo, such as "240x240", as used with GetPhoto.
+	ID               string `json:"id"`
+	Height           int    `json:"height"`
+	MediaContentType string `json:"@odata.mediaContentType"`
+	MediaEtag        string `json:"@odata.mediaEtag"`
+	Width            int    `json:"width"`
+}
+
+// listPhotoSizesResponse is the response from the list photos graph api endpoint.
+type listPhotoSizesResponse struct {
+	Value []PhotoMetadata `json:"value"`
+}
+
+// noPhotoError is the not found error of a photo endpoint, matching ErrNoPhoto as well as the
+// GraphError it wraps.
+type noPhotoError struct {
+	err *client.GraphError
+}
+
+func (e *noPhotoError) Error() string {
+	return e.err.Error()
+}
+
+func (e *noPhotoError) Unwrap() error {
+	return e.err
+}
+
+func (e *noPhotoError) Is(target error) bool {
+	return target == ErrNoPhoto
+}
+
+// photoNotFoundCodes are the error codes the photo endpoints return when a user has no photo, or
+// none of the requested size. A user which doesn't exist gets a 404 with a different code.
+var photoNotFoundCodes = map[string]bool{
+	"ErrorItemNotFound": true,
+	"ImageNotFound":     true,
+}
+
+// photoError turns the error of a photo endpoint for a missing photo into one matching ErrNoPhoto.
+func photoError(err error) error {
+	var gErr *client.GraphError
+	if errors.As(err, &gErr) && gErr.StatusCode == http.StatusNotFound && photoNotFoundCodes[gErr.Code] {
+		return &noPhotoError{err: gErr}
+	}
+	return err
+}
+
+// photoPath returns the path of a user's photo of the given size, or of the largest photo if size
+// is empty.
+func photoPath(userIDOrPrincipal string, size string) string {
+	if size == "" {
+		return fmt.Sprintf("v1.0/users/%v/photo", userIDOrPrincipal)
+	}
+	return fmt.Sprintf("v1.0/users/%v/photos/%v", userIDOrPrincipal, size)
+}
+
+// GetPhotoMetadata returns the metadata of a user's profile photo, by id or principal name. The
+// size is one of the ids returned by ListPhotoSizes, such as "48x48"; leave it empty for the
+// largest photo available. If there is no such photo, the error matches ErrNoPhoto.
+func (s *ServiceContext) GetPhotoMetadata(userIDOrPrincipal string, size string) (PhotoMetadata, error) {
+	return s.GetPhotoMetadataContext(context.Background(), userIDOrPrincipal, size)
+}
+
+// GetPhotoMetadataContext is GetPhotoMetadata, bound to the given context.
+func (s *ServiceContext) GetPhotoMetadataContext(ctx context.Context, userIDOrPrincipal string, size string) (PhotoMetadata, error) {
+	if err := s.preflight(ctx, "GetPhotoMetadata", readPhotoPermissions); err != nil {
+		return PhotoMetadata{}, err
+	}
+	b, err := internal.GraphRequestContext(ctx, s.client, "GET", photoPath(userIDOrPrincipal, size), nil, nil)
+	if err != nil {
+		return PhotoMetadata{}, photoError(err)
+	}
+	var data PhotoMetadata
+	err = json.Unmarshal(b, &data)
+	if err != nil {
+		return PhotoMetadata{}, err
+	}
+	return data, nil
+}
+
+// GetPhoto returns the image of a user's profile photo, by id or principal name, along with its
+// content type, such as "image/jpeg". The size is as in GetPhotoMetadata. The image is streamed
+// from the Graph API, so the caller has to close it. If there is no such photo, the error matches
+// ErrNoPhoto.
+func (s *ServiceContext) GetPhoto(userIDOrPrincipal string, size string) (io.ReadCloser, string, error) {
+	return s.GetPhotoContext(context.Background(), userIDOrPrincipal, size)
+}
+
+// GetPhotoContext is GetPhoto, bound to the given context, which also covers reading the image.
+func (s *ServiceContext) GetPhotoContext(ctx context.Context, userIDOrPrincipal string, size string) (io.ReadCloser, string, error) {
+	if err := s.preflight(ctx, "GetPhoto", readPhotoPermissions); err != nil {
+		return nil, "", err
+	}
+	resp, err := internal.GraphStreamRequest(ctx, s.client, "GET", photoPath(userIDOrPrincipal, size)+"/$value", nil)
+	if err != nil {
+		return nil, "", photoError(err)
+	}
+	return resp.Body, resp.Header.Get("Content-Type"), nil
+}
+
+// ListPhotoSizes returns the metadata of every size a user's profile photo is available in, by id
+// or principal name. Users without a photo have no sizes.
+func (s *ServiceContext) ListPhotoSizes(userIDOrPrincipal string) ([]PhotoMetadata, error) {
+	return s.ListPhotoSizesContext(context.Background(), userIDOrPrincipal)
+}
+
+// ListPhotoSizesContext is ListPhotoSizes, bound to the given context.
+func (s *ServiceContext) ListPhotoSizesContext(ctx context.Context, userIDOrPrincipal string) ([]PhotoMetadata, error) {
+	if err := s.preflight(ctx, "ListPhotoSizes", readPhotoPermissions); err != nil {
+		return nil, err
+	}
+	reqURL := fmt.Sprintf("v1.0/users/%v/photos", userIDOrPrincipal)
+	b, err := internal.GraphRequestContext(ctx, s.client, "GET", reqURL, nil, nil)
+	if err != nil {
+		return nil, photoError(err)
+	}
+	var data listPhotoSizesResponse
+	err = json.Unmarshal(b, &data)
+	if err != nil {
+		return nil, err
+	}
+	return data.Value, nil
+}
+
+// SetPhoto replaces the profile photo of a user, by id or principal name, with the given image of
+// the given content type, such as "image/jpeg". Microsoft recommends images no larger than 4MB.
+func (s *ServiceContext) SetPhoto(userIDOrPrincipal string, contentType string, image []byte) error {
+	return s.SetPhotoContext(context.Background(), userIDOrPrincipal, contentType, image)
+}
+
+// SetPhotoContext is SetPhoto, bound to the given context.
+func (s *ServiceContext) SetPhotoContext(ctx context.Context, userIDOrPrincipal string, contentType string, image []byte) error {
+	if err := s.preflight(ctx, "SetPhoto", writePhotoPermissions); err != nil {
+		return err
+	}
+	if len(image) == 0 {
+		return errors.New("no image provided in call to SetPhoto")
+	}
+	reqURL := fmt.Sprintf("v1.0/users/%v/photo/$value", userIDOrPrincipal)
+	_, err := internal.GraphRawRequest(ctx, s.client, "PUT", reqURL, nil, contentType, image)
+	return err
+}
@@ -0,0 +1,87 @@
+package users
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/mhoc/msgoraph/client"
+)
+
+func TestPhotoNotFound(t *testing.T) {
+	cases := []struct {
+		code    string
+		noPhoto bool
+	}{
+		{code: "ImageNotFound", noPhoto: true},
+		{code: "ErrorItemNotFound", noPhoto: true},
+		{code: "Request_ResourceNotFound", noPhoto: false},
+	}
+	for _, c := range cases {
+		s := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":{"code":"` + c.code + `","message":"not found"}}`))
+		})
+		_, _, err := s.GetPhoto("u", "")
+		if errors.Is(err, ErrNoPhoto) != c.noPhoto {
+			t.Fatalf("%v: expected ErrNoPhoto to match %v, got %v", c.code, c.noPhoto, err)
+		}
+		if !client.IsNotFound(err) {
+			t.Fatalf("%v: expected a not found error, got %v", c.code, err)
+		}
+	}
+}
+
+func TestGetPhoto(t *testing.T) {
+	image := bytes.Repeat([]byte{0xff, 0xd8}, 1<<15)
+	s := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" || r.URL.Path != "/v1.0/users/u/photos/48x48/$value" {
+			t.Errorf("unexpected request %v %v", r.Method, r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Write(image)
+	})
+	body, contentType, err := s.GetPhoto("u", "48x48")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+	if contentType != "image/jpeg" {
+		t.Fatalf("unexpected content type %q", contentType)
+	}
+	b, err := ioutil.ReadAll(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, image) {
+		t.Fatalf("expected the %v bytes of the image, got %v", len(image), len(b))
+	}
+}
+
+func TestSetPhoto(t *testing.T) {
+	image := []byte{0x89, 'P', 'N', 'G'}
+	s := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PUT" || r.URL.Path != "/v1.0/users/u/photo/$value" {
+			t.Errorf("unexpected request %v %v", r.Method, r.URL.Path)
+		}
+		if got := r.Header.Get("Content-Type"); got != "image/png" {
+			t.Errorf("unexpected content type %q", got)
+		}
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(b, image) {
+			t.Errorf("expected the raw image, got %q", b)
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	if err := s.SetPhoto("u", "image/png", image); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetPhoto("u", "image/png", nil); err == nil {
+		t.Fatal("expected an error without an image")
+	}
+}
@@ -1,12 +1,14 @@
 package users
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/url"
 
 	"github.com/mhoc/msgoraph/client"
 	"github.com/mhoc/msgoraph/internal"
+	"github.com/mhoc/msgoraph/odata"
 )
 
 // CreateUserRequest is all the available args you can set when creating a user.
@@ -25,7 +27,8 @@ type GetUserResponse struct {
 	User
 }
 
-// ListUsersResponse is the Response from the list users graph api endpoint
+// ListUsersResponse is the Response from the list users graph api endpoint. ListUsersWithFields
+// pages through these with a UserIterator.
 type ListUsersResponse struct {
 	Context  string `json:"@odata.context"`
 	NextPage string `json:"@odata.nextLink"`
@@ -43,45 +46,20 @@ func Service(client client.Client) *ServiceContext {
 	return &ServiceContext{client: client}
 }
 
-// UpdateUserRequest contains the request body to update a user.
-type UpdateUserRequest struct {
-	AboutMe               string            `json:"aboutMe"`
-	AccountEnabled        string            `json:"accountEnabled"`
-	AssignedLicenses      []AssignedLicense `json:"assignedLicenses"`
-	Birthday              string            `json:"birthday"`
-	City                  string            `json:"city"`
-	Country               string            `json:"country"`
-	Department            string            `json:"department"`
-	DisplayName           string            `json:"displayName"`
-	GivenName             string            `json:"givenName"`
-	HireDate              string            `json:"hireDate"`
-	Interests             []string          `json:"interests"`
-	JobTitle              string            `json:"jobTitle"`
-	MailNickname          string            `json:"mailNickname"`
-	MobilePhone           string            `json:"mobilePhone"`
-	MySite                string            `json:"mySite"`
-	OfficeLocation        string            `json:"officeLocation"`
-	OnPremisesImmutableID string            `json:"onPremisesImmutableId"`
-	PasswordPolicies      string            `json:"passwordPolicies"`
-	PasswordProfile       PasswordProfile   `json:"passwordProfile"`
-	PastProjects          []string          `json:"pastProjects"`
-	PostalCode            string            `json:"postalCode"`
-	PreferredLanguage     string            `json:"preferredLanguage"`
-	PreferredName         string            `json:"preferredName"`
-	Responsibilities      []string          `json:"responsibilities"`
-	Schools               []string          `json:"schools"`
-	Skills                []string          `json:"skills"`
-	State                 string            `json:"state"`
-	StreetAddress         string            `json:"streetAddress"`
-	Surname               string            `json:"surname"`
-	UsageLocation         string            `json:"usageLocation"`
-	UserPrincipalName     string            `json:"userPrincipalName"`
-	UserType              string            `json:"userType"`
-}
-
 // CreateUser creates a new user in the tenant.
 func (s *ServiceContext) CreateUser(createUser CreateUserRequest) (User, error) {
-	body, err := internal.GraphRequest(s.client, "POST", "v1.0/users", nil, createUser)
+	return s.CreateUserContext(context.Background(), createUser)
+}
+
+// CreateUserContext is CreateUser, bound to the given context.
+func (s *ServiceContext) CreateUserContext(ctx context.Context, createUser CreateUserRequest) (User, error) {
+	if err := s.preflight(ctx, "CreateUser", createUserPermissions); err != nil {
+		return User{}, err
+	}
+	body, err := internal.GraphRequestContext(ctx, s.client, "POST", "v1.0/users", nil, createUser)
+	if err != nil {
+		return User{}, err
+	}
 	var data GetUserResponse
 	err = json.Unmarshal(body, &data)
 	if err != nil {
@@ -92,35 +70,77 @@ func (s *ServiceContext) CreateUser(createUser CreateUserRequest) (User, error)
 
 // DeleteUser deletes an existing user by id or principal name.
 func (s *ServiceContext) DeleteUser(userIDOrPrincipal string) error {
+	return s.DeleteUserContext(context.Background(), userIDOrPrincipal)
+}
+
+// DeleteUserContext is DeleteUser, bound to the given context.
+func (s *ServiceContext) DeleteUserContext(ctx context.Context, userIDOrPrincipal string) error {
+	if err := s.preflight(ctx, "DeleteUser", deleteUserPermissions); err != nil {
+		return err
+	}
 	reqURL := fmt.Sprintf("v1.0/users/%v", userIDOrPrincipal)
-	_, err := internal.GraphRequest(s.client, "DELETE", reqURL, nil, nil)
+	_, err := internal.GraphRequestContext(ctx, s.client, "DELETE", reqURL, nil, nil)
 	return err
 }
 
 // GetUser returns a single user by id or principal name, with the Microsoft default fields
-// provided, identical to those specified in UserDefaultFields.
+// provided, identical to those specified in UserDefaultFields. If the user does not exist, the
+// error satisfies client.IsNotFound.
 func (s *ServiceContext) GetUser(userIDOrPrincipal string) (User, error) {
-	return s.GetUserWithFields(userIDOrPrincipal, UserDefaultFields)
+	return s.GetUserContext(context.Background(), userIDOrPrincipal)
+}
+
+// GetUserContext is GetUser, bound to the given context.
+func (s *ServiceContext) GetUserContext(ctx context.Context, userIDOrPrincipal string) (User, error) {
+	return s.GetUserWithFieldsContext(ctx, userIDOrPrincipal, UserDefaultFields)
 }
 
 // GetUserWithFields returns a single user by id or principal name. You need to specify a list of
 // fields you want to project on the user returned. You can specify UserDefaultFields or
 // UserAllFields, or customize it depending on what you want.
 func (s *ServiceContext) GetUserWithFields(userIDOrPrincipal string, projection []Field) (User, error) {
-	if len(projection) == 0 {
-		return User{}, fmt.Errorf("no fields provided in call to Users")
+	return s.GetUserWithFieldsContext(context.Background(), userIDOrPrincipal, projection)
+}
+
+// GetUserWithFieldsContext is GetUserWithFields, bound to the given context.
+func (s *ServiceContext) GetUserWithFieldsContext(ctx context.Context, userIDOrPrincipal string, projection []Field) (User, error) {
+	if err := s.preflight(ctx, "GetUser", getUserPermissions); err != nil {
+		return User{}, err
 	}
-	selectFields := ""
-	for i, requestField := range projection {
-		if i != 0 {
-			selectFields += ","
-		}
-		selectFields += string(requestField)
+	v, err := selectParams(projection)
+	if err != nil {
+		return User{}, err
 	}
-	v := url.Values{}
-	v.Set("$select", selectFields)
 	reqURL := fmt.Sprintf("v1.0/users/%v", userIDOrPrincipal)
-	b, err := internal.GraphRequest(s.client, "GET", reqURL, v, nil)
+	b, err := internal.GraphRequestContext(ctx, s.client, "GET", reqURL, v, nil)
+	if err != nil {
+		return User{}, err
+	}
+	var data GetUserResponse
+	err = json.Unmarshal(b, &data)
+	if err != nil {
+		return User{}, err
+	}
+	return data.User, nil
+}
+
+// GetUserWithQuery returns a single user by id or principal name, with the $select and $expand
+// parameters of the given query applied; its other options only apply to lists and aren't sent. If
+// the query selects nothing, the user is projected with UserDefaultFields.
+func (s *ServiceContext) GetUserWithQuery(userIDOrPrincipal string, q *odata.Query) (User, error) {
+	return s.GetUserWithQueryContext(context.Background(), userIDOrPrincipal, q)
+}
+
+// GetUserWithQueryContext is GetUserWithQuery, bound to the given context.
+func (s *ServiceContext) GetUserWithQueryContext(ctx context.Context, userIDOrPrincipal string, q *odata.Query) (User, error) {
+	if err := s.preflight(ctx, "GetUser", getUserPermissions); err != nil {
+		return User{}, err
+	}
+	reqURL := fmt.Sprintf("v1.0/users/%v", userIDOrPrincipal)
+	b, err := internal.GraphRequestContext(ctx, s.client, "GET", reqURL, entityQueryParams(q), nil)
+	if err != nil {
+		return User{}, err
+	}
 	var data GetUserResponse
 	err = json.Unmarshal(b, &data)
 	if err != nil {
@@ -132,49 +152,107 @@ func (s *ServiceContext) GetUserWithFields(userIDOrPrincipal string, projection
 // ListUsers returns all users in the tenant, with each user projected with the Microsoft-defined
 // default fields identical to UserDefaultFields.
 func (s *ServiceContext) ListUsers() ([]User, error) {
-	return s.ListUsersWithFields(UserDefaultFields)
+	return s.ListUsersContext(context.Background())
+}
+
+// ListUsersContext is ListUsers, bound to the given context.
+func (s *ServiceContext) ListUsersContext(ctx context.Context) ([]User, error) {
+	return s.ListUsersWithFieldsContext(ctx, UserDefaultFields)
 }
 
 // ListUsersWithFields returns the users on a tenant's azure instance. You need to specify a list of
 // fields you want to project on the users returned. You can specify UserDefaultFields or
 // UserAllFields, or customize it depending on what you want.
 func (s *ServiceContext) ListUsersWithFields(projection []Field) ([]User, error) {
-	getUserPage := func(url string) ([]User, string, error) {
-		b, err := internal.BasicGraphRequest(s.client, "GET", url)
-		var data ListUsersResponse
-		err = json.Unmarshal(b, &data)
-		if err != nil {
-			return nil, "", err
-		}
-		return data.Value, data.NextPage, nil
-	}
+	return s.ListUsersWithFieldsContext(context.Background(), projection)
+}
+
+// ListUsersWithFieldsContext is ListUsersWithFields, bound to the given context. Cancelling the
+// context stops pagination at the next page request.
+func (s *ServiceContext) ListUsersWithFieldsContext(ctx context.Context, projection []Field) ([]User, error) {
 	var users []User
+	it := s.IterateUsersWithFieldsContext(ctx, projection, 0)
+	for it.Next() {
+		users = append(users, it.User())
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// selectParams returns the query parameters projecting the given fields onto the users returned.
+func selectParams(projection []Field) (url.Values, error) {
 	if len(projection) == 0 {
 		return nil, fmt.Errorf("no fields provided in call to Users")
 	}
-	filter := "$select="
-	for _, requestField := range projection {
-		filter += string(requestField) + ","
-	}
-	nextURL := fmt.Sprintf("https://graph.microsoft.com/v1.0/users?%v", filter)
-	for nextURL != "" {
-		pageUsers, next, err := getUserPage(nextURL)
-		if err != nil {
-			return nil, err
-		}
-		for _, pageUser := range pageUsers {
-			users = append(users, pageUser)
+	selectFields := ""
+	for i, requestField := range projection {
+		if i != 0 {
+			selectFields += ","
 		}
-		nextURL = next
+		selectFields += string(requestField)
+	}
+	v := url.Values{}
+	v.Set("$select", selectFields)
+	return v, nil
+}
+
+// ListUsersWithQuery returns every user in the tenant matching the given query. If the query
+// selects nothing, the users are projected with UserDefaultFields.
+func (s *ServiceContext) ListUsersWithQuery(q *odata.Query) ([]User, error) {
+	return s.ListUsersWithQueryContext(context.Background(), q)
+}
+
+// ListUsersWithQueryContext is ListUsersWithQuery, bound to the given context.
+func (s *ServiceContext) ListUsersWithQueryContext(ctx context.Context, q *odata.Query) ([]User, error) {
+	var users []User
+	it := s.IterateUsersWithQueryContext(ctx, q)
+	for it.Next() {
+		users = append(users, it.User())
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
 	}
 	return users, nil
 }
 
+// queryParams returns the parameters of the query, defaulting the projection to UserDefaultFields.
+func queryParams(q *odata.Query) url.Values {
+	v := q.Values()
+	if v.Get("$select") == "" {
+		defaults, _ := selectParams(UserDefaultFields)
+		v.Set("$select", defaults.Get("$select"))
+	}
+	return v
+}
+
+// entityQueryParams is queryParams, keeping only the $select and $expand parameters, which are the
+// ones that apply to a single user.
+func entityQueryParams(q *odata.Query) url.Values {
+	all := queryParams(q)
+	v := url.Values{}
+	for _, name := range []string{"$select", "$expand"} {
+		if all.Get(name) != "" {
+			v.Set(name, all.Get(name))
+		}
+	}
+	return v
+}
+
 // UpdateUser updates a user in the microsoft graph api, by userid or principal name, which is
-// usually their email address. You can provide as few or many fields in the request as you'd like
-// to update.
+// usually their email address. Only the fields set on the request, and those listed in its
+// NullFields, are changed; see UpdateUserRequest.
 func (s *ServiceContext) UpdateUser(userIDOrPrincipal string, u UpdateUserRequest) error {
+	return s.UpdateUserContext(context.Background(), userIDOrPrincipal, u)
+}
+
+// UpdateUserContext is UpdateUser, bound to the given context.
+func (s *ServiceContext) UpdateUserContext(ctx context.Context, userIDOrPrincipal string, u UpdateUserRequest) error {
+	if err := s.preflight(ctx, "UpdateUser", updateUserPermissions); err != nil {
+		return err
+	}
 	reqURL := fmt.Sprintf("v1.0/users/%v", userIDOrPrincipal)
-	_, err := internal.GraphRequest(s.client, "PATCH", reqURL, nil, u)
+	_, err := internal.GraphRequestContext(ctx, s.client, "PATCH", reqURL, nil, u)
 	return err
 }
@@ -0,0 +1,41 @@
+package users
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/mhoc/msgoraph/odata"
+)
+
+func TestGetUserWithQuerySendsOnlySelectAndExpand(t *testing.T) {
+	s := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("$select") != "id,displayName" || q.Get("$expand") != "manager" {
+			t.Errorf("expected the projection and expansion to be sent, got %v", q)
+		}
+		for _, name := range []string{"$filter", "$top", "$search", "$count", "$orderby"} {
+			if _, ok := q[name]; ok {
+				t.Errorf("expected %v not to be sent for a single user", name)
+			}
+		}
+		if got := r.Header.Get("ConsistencyLevel"); got != "" {
+			t.Errorf("unexpected ConsistencyLevel %q", got)
+		}
+		w.Write([]byte(`{"id":"u","displayName":"Adele"}`))
+	})
+	q := odata.NewQuery().
+		Select("id", "displayName").
+		Expand("manager").
+		Filter(odata.Eq("accountEnabled", true)).
+		Search("displayName", "Adele").
+		OrderBy("displayName").
+		Count().
+		Top(5)
+	u, err := s.GetUserWithQuery("u", q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.DisplayName == nil || *u.DisplayName != "Adele" {
+		t.Fatalf("unexpected user %+v", u)
+	}
+}
@@ -0,0 +1,139 @@
+package users
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// UpdateUserRequest contains the request body to update a user. It's sent as a partial update:
+// fields left nil are omitted and keep their current value on the user. Use String and Bool to set
+// scalar fields, and list a field in NullFields to clear it. Licenses can't be changed through an
+// update.
+type UpdateUserRequest struct {
+	AboutMe                       *string                        `json:"aboutMe,omitempty"`
+	AccountEnabled                *bool                          `json:"accountEnabled,omitempty"`
+	Birthday                      *string                        `json:"birthday,omitempty"`
+	BusinessPhones                []string                       `json:"businessPhones,omitempty"`
+	City                          *string                        `json:"city,omitempty"`
+	CompanyName                   *string                        `json:"companyName,omitempty"`
+	Country                       *string                        `json:"country,omitempty"`
+	Department                    *string                        `json:"department,omitempty"`
+	DisplayName                   *string                        `json:"displayName,omitempty"`
+	EmployeeID                    *string                        `json:"employeeId,omitempty"`
+	GivenName                     *string                        `json:"givenName,omitempty"`
+	HireDate                      *string                        `json:"hireDate,omitempty"`
+	Interests                     []string                       `json:"interests,omitempty"`
+	JobTitle                      *string                        `json:"jobTitle,omitempty"`
+	MailNickname                  *string                        `json:"mailNickname,omitempty"`
+	MobilePhone                   *string                        `json:"mobilePhone,omitempty"`
+	MySite                        *string                        `json:"mySite,omitempty"`
+	OfficeLocation                *string                        `json:"officeLocation,omitempty"`
+	OnPremisesExtensionAttributes *OnPremisesExtensionAttributes `json:"onPremisesExtensionAttributes,omitempty"`
+	OnPremisesImmutableID         *string                        `json:"onPremisesImmutableId,omitempty"`
+	OtherMails                    []string                       `json:"otherMails,omitempty"`
+	PasswordPolicies              *string                        `json:"passwordPolicies,omitempty"`
+	PasswordProfile               *PasswordProfile               `json:"passwordProfile,omitempty"`
+	PastProjects                  []string                       `json:"pastProjects,omitempty"`
+	PostalCode                    *string                        `json:"postalCode,omitempty"`
+	PreferredLanguage             *string                        `json:"preferredLanguage,omitempty"`
+	PreferredName                 *string                        `json:"preferredName,omitempty"`
+	Responsibilities              []string                       `json:"responsibilities,omitempty"`
+	Schools                       []string                       `json:"schools,omitempty"`
+	Skills                        []string                       `json:"skills,omitempty"`
+	State                         *string                        `json:"state,omitempty"`
+	StreetAddress                 *string                        `json:"streetAddress,omitempty"`
+	Surname                       *string                        `json:"surname,omitempty"`
+	UsageLocation                 *string                        `json:"usageLocation,omitempty"`
+	UserPrincipalName             *string                        `json:"userPrincipalName,omitempty"`
+	UserType                      *string                        `json:"userType,omitempty"`
+
+	// NullFields lists the fields to clear on the user. Single valued fields are sent as null and
+	// collections as an empty list. A field can't be both set and cleared.
+	NullFields []Field `json:"-"`
+}
+
+// MarshalJSON encodes the request as a partial update, adding the cleared fields to the set ones.
+func (u UpdateUserRequest) MarshalJSON() ([]byte, error) {
+	type request UpdateUserRequest
+	return marshalPatch(request(u), FieldNames(u.NullFields))
+}
+
+// OnPremisesExtensionAttributes are the fifteen free-form attributes of a user synchronized from
+// an on-premises directory, also known as the Exchange custom attributes. They can only be updated
+// for users which aren't synchronized. Like UpdateUserRequest, only the attributes which are set,
+// or listed in NullFields, are changed.
+type OnPremisesExtensionAttributes struct {
+	ExtensionAttribute1  *string `json:"extensionAttribute1,omitempty"`
+	ExtensionAttribute2  *string `json:"extensionAttribute2,omitempty"`
+	ExtensionAttribute3  *string `json:"extensionAttribute3,omitempty"`
+	ExtensionAttribute4  *string `json:"extensionAttribute4,omitempty"`
+	ExtensionAttribute5  *string `json:"extensionAttribute5,omitempty"`
+	ExtensionAttribute6  *string `json:"extensionAttribute6,omitempty"`
+	ExtensionAttribute7  *string `json:"extensionAttribute7,omitempty"`
+	ExtensionAttribute8  *string `json:"extensionAttribute8,omitempty"`
+	ExtensionAttribute9  *string `json:"extensionAttribute9,omitempty"`
+	ExtensionAttribute10 *string `json:"extensionAttribute10,omitempty"`
+	ExtensionAttribute11 *string `json:"extensionAttribute11,omitempty"`
+	ExtensionAttribute12 *string `json:"extensionAttribute12,omitempty"`
+	ExtensionAttribute13 *string `json:"extensionAttribute13,omitempty"`
+	ExtensionAttribute14 *string `json:"extensionAttribute14,omitempty"`
+	ExtensionAttribute15 *string `json:"extensionAttribute15,omitempty"`
+
+	// NullFields lists the json names of the attributes to clear, such as "extensionAttribute1".
+	NullFields []string `json:"-"`
+}
+
+// MarshalJSON encodes the attributes as a partial update, adding the cleared attributes to the set
+// ones.
+func (a OnPremisesExtensionAttributes) MarshalJSON() ([]byte, error) {
+	type attributes OnPremisesExtensionAttributes
+	return marshalPatch(attributes(a), a.NullFields)
+}
+
+// String returns a pointer to the given string, for setting fields of an UpdateUserRequest.
+func String(v string) *string {
+	return &v
+}
+
+// Bool returns a pointer to the given bool, for setting fields of an UpdateUserRequest.
+func Bool(v bool) *bool {
+	return &v
+}
+
+// marshalPatch encodes a struct of omitempty fields, then adds each of the named fields as null, or
+// as an empty list if it's a slice. It fails if a named field doesn't exist or is already set.
+func marshalPatch(v interface{}, nullFields []string) ([]byte, error) {
+	b, err := json.Marshal(v)
+	if err != nil || len(nullFields) == 0 {
+		return b, err
+	}
+	var body map[string]json.RawMessage
+	if err := json.Unmarshal(b, &body); err != nil {
+		return nil, err
+	}
+	kinds := map[string]reflect.Kind{}
+	t := reflect.TypeOf(v)
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name != "" && name != "-" {
+			kinds[name] = t.Field(i).Type.Kind()
+		}
+	}
+	for _, name := range nullFields {
+		kind, ok := kinds[name]
+		if !ok {
+			return nil, fmt.Errorf("cannot clear unknown field %q", name)
+		}
+		if _, set := body[name]; set {
+			return nil, fmt.Errorf("field %q is both set and cleared", name)
+		}
+		if kind == reflect.Slice {
+			body[name] = json.RawMessage("[]")
+		} else {
+			body[name] = json.RawMessage("null")
+		}
+	}
+	return json.Marshal(body)
+}
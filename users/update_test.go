@@ -0,0 +1,81 @@
+package users
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestUpdateUserRequestMarshal(t *testing.T) {
+	cases := []struct {
+		name     string
+		request  UpdateUserRequest
+		expected string
+		fails    bool
+	}{
+		{
+			name:     "unset fields are omitted",
+			request:  UpdateUserRequest{},
+			expected: `{}`,
+		},
+		{
+			name: "set fields are sent",
+			request: UpdateUserRequest{
+				AccountEnabled: Bool(false),
+				BusinessPhones: []string{"+1 555 0100"},
+				JobTitle:       String("Engineer"),
+			},
+			expected: `{"accountEnabled":false,"businessPhones":["+1 555 0100"],"jobTitle":"Engineer"}`,
+		},
+		{
+			name: "cleared fields are sent as null or an empty list",
+			request: UpdateUserRequest{
+				DisplayName: String("Adele"),
+				NullFields:  []Field{FieldOfficeLocation, FieldBusinessPhones},
+			},
+			expected: `{"businessPhones":[],"displayName":"Adele","officeLocation":null}`,
+		},
+		{
+			name: "extension attributes are a nested partial update",
+			request: UpdateUserRequest{
+				OnPremisesExtensionAttributes: &OnPremisesExtensionAttributes{
+					ExtensionAttribute1: String("cost center 12"),
+					NullFields:          []string{"extensionAttribute2"},
+				},
+			},
+			expected: `{"onPremisesExtensionAttributes":{"extensionAttribute1":"cost center 12","extensionAttribute2":null}}`,
+		},
+		{
+			name: "a field can't be both set and cleared",
+			request: UpdateUserRequest{
+				JobTitle:   String("Engineer"),
+				NullFields: []Field{FieldJobTitle},
+			},
+			fails: true,
+		},
+		{
+			name: "an extension attribute can't be both set and cleared",
+			request: UpdateUserRequest{
+				OnPremisesExtensionAttributes: &OnPremisesExtensionAttributes{
+					ExtensionAttribute1: String("cost center 12"),
+					NullFields:          []string{"extensionAttribute1"},
+				},
+			},
+			fails: true,
+		},
+	}
+	for _, c := range cases {
+		b, err := json.Marshal(c.request)
+		if c.fails {
+			if err == nil {
+				t.Fatalf("%v: expected an error, got %s", c.name, b)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%v: %v", c.name, err)
+		}
+		if string(b) != c.expected {
+			t.Fatalf("%v: expected %v, got %s", c.name, c.expected, b)
+		}
+	}
+}